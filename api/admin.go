@@ -0,0 +1,31 @@
+package api
+
+import "fmt"
+
+// Request header `Endpoint: Admin.ListTasks`
+type AdminListTasksRequest struct {
+	ListTasksRequest
+	// Пространство имен задач. Пусто - задачи всех пространств.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (r AdminListTasksRequest) Validate() error {
+	if r.Mine {
+		return fmt.Errorf("поле `mine` не поддерживается")
+	}
+	return r.ListTasksRequest.Validate()
+}
+
+// Request header `Endpoint: Admin.ListNamespaces`
+type ListNamespacesRequest struct{}
+
+type NamespaceSummary struct {
+	Namespace string `json:"namespace"`
+	TaskCount int    `json:"task_count"`
+	// Задачи, которые еще не завершились.
+	ActiveCount int `json:"active_count"`
+}
+
+type ListNamespacesResponse struct {
+	Namespaces []NamespaceSummary `json:"namespaces"`
+}
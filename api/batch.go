@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"task-api/pkg/labels"
+)
+
+// Максимальное число задач в пакетном запросе.
+const MaxBatchSize = 1000
+
+// Ошибка обработки одного элемента пакетного запроса.
+type BatchError struct {
+	// Код ошибки: `bad_input`, `not_found`, `unavailable`, `conflict`, `forbidden` или `internal`.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Результат обработки одного элемента пакетного запроса.
+// Error отсутствует, если элемент обработан успешно.
+type BatchResult struct {
+	Index  int         `json:"index"`
+	TaskID int         `json:"task_id,omitempty"`
+	Status TaskStatus  `json:"status,omitempty"`
+	Error  *BatchError `json:"error,omitempty"`
+}
+
+// Request header `Endpoint: Tasks.CreateTasks`
+type CreateTasksRequest struct {
+	Tasks []CreateTaskRequest `json:"tasks"`
+	// Создать все задачи или ни одной. Ошибка любой задачи отклоняет весь запрос.
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+func (r CreateTasksRequest) Validate() error {
+	if len(r.Tasks) == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `tasks`")
+	}
+	if len(r.Tasks) > MaxBatchSize {
+		return fmt.Errorf("в запросе больше %d задач", MaxBatchSize)
+	}
+	return nil
+}
+
+type CreateTasksResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Request header `Endpoint: Tasks.CancelTasks`
+type CancelTasksRequest struct {
+	TaskIDs []uint64 `json:"task_ids,omitempty"`
+	// Отменить незавершенные задачи, подходящие под селектор меток, вместо `task_ids`.
+	LabelSelector string `json:"label_selector,omitempty"`
+}
+
+func (r CancelTasksRequest) Validate() error {
+	return validateTargets(r.TaskIDs, r.LabelSelector)
+}
+
+type CancelTasksResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Request header `Endpoint: Tasks.DeleteTasks`
+type DeleteTasksRequest struct {
+	TaskIDs []uint64 `json:"task_ids,omitempty"`
+	// Удалить задачи, подходящие под селектор меток, вместо `task_ids`.
+	LabelSelector string `json:"label_selector,omitempty"`
+}
+
+func (r DeleteTasksRequest) Validate() error {
+	return validateTargets(r.TaskIDs, r.LabelSelector)
+}
+
+type DeleteTasksResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Задачи пакетного запроса задаются списком id или селектором меток.
+func validateTargets(ids []uint64, selector string) error {
+	if selector == "" {
+		return validateTaskIDs(ids)
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("поля `task_ids` и `label_selector` взаимоисключающие")
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("поле `label_selector`: %s", err)
+	}
+	return nil
+}
+
+func validateTaskIDs(ids []uint64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `task_ids`")
+	}
+	if len(ids) > MaxBatchSize {
+		return fmt.Errorf("в запросе больше %d задач", MaxBatchSize)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package api
+
+// Request header `Endpoint: Quotas.Get`
+type GetQuotasRequest struct{}
+
+type Quota struct {
+	// Область квоты: `task_type` - тип задачи, `owner` - ключ API.
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+	// Пространство имен, в котором действует квота типа задачи.
+	Namespace string `json:"namespace,omitempty"`
+	// Максимум одновременно выполняемых задач.
+	Limit   int `json:"limit"`
+	Running int `json:"running"`
+	// Задачи в очереди, в том числе ожидающие освобождения квоты.
+	Queued int `json:"queued"`
+}
+
+type GetQuotasResponse struct {
+	Quotas []Quota `json:"quotas"`
+}
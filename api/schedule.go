@@ -0,0 +1,118 @@
+package api
+
+import "fmt"
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive ScheduleStatus = "active"
+	ScheduleStatusPaused ScheduleStatus = "paused"
+)
+
+type Schedule struct {
+	ScheduleID int            `json:"schedule_id"`
+	Cron       string         `json:"cron"`
+	TaskType   string         `json:"task_type"`
+	Options    map[string]any `json:"options"`
+	Status     ScheduleStatus `json:"status"`
+	CreatedAt  string         `json:"created_at"`
+	NextRunAt  string         `json:"next_run_at,omitempty"`
+	LastRunAt  string         `json:"last_run_at,omitempty"`
+	LastTaskID int            `json:"last_task_id,omitempty"`
+	LastError  string         `json:"last_error,omitempty"`
+	Owner      string         `json:"owner,omitempty"`
+}
+
+// Request header `Endpoint: Schedules.Create`
+type CreateScheduleRequest struct {
+	// Выражение cron из 5 полей, время в UTC.
+	Cron     string         `json:"cron"`
+	TaskType string         `json:"task_type"`
+	Options  map[string]any `json:"options"`
+}
+
+func (r CreateScheduleRequest) Validate() error {
+	if r.Cron == "" {
+		return fmt.Errorf("тело запроса не содержит поле `cron`")
+	}
+	if r.TaskType == "" {
+		return fmt.Errorf("тело запроса не содержит поле `task_type`")
+	}
+	return nil
+}
+
+type CreateScheduleResponse struct {
+	Schedule
+}
+
+// Request header `Endpoint: Schedules.List`
+type ListSchedulesRequest struct{}
+
+type ListSchedulesResponse struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+// Request header `Endpoint: Schedules.Pause`
+type PauseScheduleRequest struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+func (r PauseScheduleRequest) Validate() error {
+	if r.ScheduleID == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `schedule_id`")
+	}
+	return nil
+}
+
+type PauseScheduleResponse struct {
+	Schedule
+}
+
+// Request header `Endpoint: Schedules.Resume`
+type ResumeScheduleRequest struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+func (r ResumeScheduleRequest) Validate() error {
+	if r.ScheduleID == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `schedule_id`")
+	}
+	return nil
+}
+
+type ResumeScheduleResponse struct {
+	Schedule
+}
+
+// Request header `Endpoint: Schedules.Delete`
+type DeleteScheduleRequest struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+func (r DeleteScheduleRequest) Validate() error {
+	if r.ScheduleID == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `schedule_id`")
+	}
+	return nil
+}
+
+type DeleteScheduleResponse struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+// Request header `Endpoint: Schedules.ListRuns`
+type ListScheduleRunsRequest struct {
+	ScheduleID int `json:"schedule_id"`
+}
+
+func (r ListScheduleRunsRequest) Validate() error {
+	if r.ScheduleID == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `schedule_id`")
+	}
+	return nil
+}
+
+type ListScheduleRunsResponse struct {
+	ScheduleID int           `json:"schedule_id"`
+	Runs       []TaskSummary `json:"runs"`
+}
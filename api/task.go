@@ -1,25 +1,148 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"task-api/pkg/labels"
+	"time"
+)
 
 type TaskStatus string
 
 const (
-	TaskStatusCreated  TaskStatus = "created"
-	TaskStatusAborted  TaskStatus = "aborted"
-	TaskStatusExecuted TaskStatus = "executed"
+	TaskStatusQueued      TaskStatus = "queued"
+	TaskStatusRunning     TaskStatus = "running"
+	TaskStatusAborted     TaskStatus = "aborted"
+	TaskStatusExecuted    TaskStatus = "executed"
+	TaskStatusFailed      TaskStatus = "failed"
+	TaskStatusInterrupted TaskStatus = "interrupted"
+	TaskStatusRetrying    TaskStatus = "retrying"
+	TaskStatusTimedOut    TaskStatus = "timed_out"
+	TaskStatusScheduled   TaskStatus = "scheduled"
+	TaskStatusBlocked     TaskStatus = "blocked"
+)
+
+func (s TaskStatus) valid() bool {
+	switch s {
+	case TaskStatusQueued, TaskStatusRunning, TaskStatusAborted, TaskStatusExecuted, TaskStatusFailed,
+		TaskStatusInterrupted, TaskStatusRetrying, TaskStatusTimedOut, TaskStatusScheduled, TaskStatusBlocked:
+		return true
+	}
+	return false
+}
+
+const (
+	MinTaskPriority = -100
+	MaxTaskPriority = 100
+)
+
+type TaskSort string
+
+const (
+	// По времени создания (по умолчанию), по умолчанию по возрастанию.
+	TaskSortCreated TaskSort = "created"
+	// По приоритету, по умолчанию по убыванию.
+	TaskSortPriority TaskSort = "priority"
+)
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
 )
 
+// Максимальное число меток задачи.
+const MaxTaskLabels = 64
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// Политика повторного выполнения задачи после ошибки.
+type RetryPolicy struct {
+	MaxAttempts       int     `json:"max_attempts"`
+	InitialBackoffSec float64 `json:"initial_backoff_sec"`
+	Multiplier        float64 `json:"multiplier"`
+	Jitter            float64 `json:"jitter"`
+	// Коды ошибок, после которых задача повторяется. Пусто - после любых.
+	RetryableErrors []string `json:"retryable_errors"`
+}
+
 // Request header `Endpoint: Tasks.Create`
 type CreateTaskRequest struct {
 	TaskType string         `json:"task_type"`
 	Options  map[string]any `json:"options"`
+	Retry    *RetryPolicy   `json:"retry,omitempty"`
+	// Ограничение времени одной попытки выполнения, в секундах.
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+	// Крайний срок выполнения в формате RFC 3339.
+	Deadline string `json:"deadline,omitempty"`
+	// Время запуска в формате RFC 3339.
+	RunAt string `json:"run_at,omitempty"`
+	// Задержка запуска в секундах.
+	DelaySec int `json:"delay_sec,omitempty"`
+	// Задачи, после успешного выполнения которых запускается задача.
+	DependsOn []int `json:"depends_on,omitempty"`
+	// Приоритет от -100 до 100: задачи с большим приоритетом выполняются раньше.
+	Priority int `json:"priority,omitempty"`
+	// Ключ идемпотентности: повтор запроса с тем же ключом вернет уже созданную задачу.
+	// Можно передать в заголовке `Idempotency-Key`.
+	IdempotencyKey string `json:"idempotency_key,omitempty" header:"Idempotency-Key"`
+	// Произвольные метки задачи, например владелец, проект или окружение.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
+const MaxIdempotencyKeyLen = 255
+
 func (r CreateTaskRequest) Validate() error {
 	if r.TaskType == "" {
 		return fmt.Errorf("тело запроса не содержит поле `task_type`")
 	}
+	if r.Retry != nil && r.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("поле `retry.max_attempts` должно быть >= 1")
+	}
+	if r.TimeoutSec < 0 {
+		return fmt.Errorf("поле `timeout_sec` не может быть отрицательным")
+	}
+	if r.Deadline != "" {
+		if _, err := time.Parse(time.RFC3339, r.Deadline); err != nil {
+			return fmt.Errorf("поле `deadline` должно быть в формате RFC 3339: %s", err)
+		}
+	}
+	if r.RunAt != "" && r.DelaySec != 0 {
+		return fmt.Errorf("поля `run_at` и `delay_sec` взаимоисключающие")
+	}
+	if r.RunAt != "" {
+		if _, err := time.Parse(time.RFC3339, r.RunAt); err != nil {
+			return fmt.Errorf("поле `run_at` должно быть в формате RFC 3339: %s", err)
+		}
+	}
+	if r.DelaySec < 0 {
+		return fmt.Errorf("поле `delay_sec` не может быть отрицательным")
+	}
+	for _, id := range r.DependsOn {
+		if id <= 0 {
+			return fmt.Errorf("поле `depends_on` должно содержать id задач")
+		}
+	}
+	if r.Priority < MinTaskPriority || r.Priority > MaxTaskPriority {
+		return fmt.Errorf("поле `priority` должно быть в диапазоне [%d, %d]", MinTaskPriority, MaxTaskPriority)
+	}
+	if len(r.IdempotencyKey) > MaxIdempotencyKeyLen {
+		return fmt.Errorf("ключ идемпотентности длиннее %d символов", MaxIdempotencyKeyLen)
+	}
+	if len(r.Labels) > MaxTaskLabels {
+		return fmt.Errorf("у задачи больше %d меток", MaxTaskLabels)
+	}
+	for key, value := range r.Labels {
+		if err := labels.ValidateKey(key); err != nil {
+			return err
+		}
+		if err := labels.ValidateValue(value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -28,6 +151,7 @@ type CreateTaskResponse struct {
 	TaskType  string         `json:"task_type"`
 	Options   map[string]any `json:"options"`
 	CreatedAt string         `json:"created_at"`
+	Status    TaskStatus     `json:"status"`
 }
 
 // Request header `Endpoint: Tasks.TaskDetails`
@@ -43,27 +167,118 @@ func (r GetTaskDetailsRequest) Validate() error {
 }
 
 type GetTaskDetailsResponse struct {
-	TaskID        int            `json:"task_id"`
-	TaskType      string         `json:"task_type"`
-	Options       map[string]any `json:"options"`
-	CreatedAt     string         `json:"created_at"`
-	Status        TaskStatus     `json:"status"`
-	ExecutedAt    string         `json:"executed_at,omitempty"`
-	AbortedAt     string         `json:"aborted_at,omitempty"`
-	ExecutionTime string         `json:"execution_time"`
+	TaskID        int               `json:"task_id"`
+	TaskType      string            `json:"task_type"`
+	Options       map[string]any    `json:"options"`
+	CreatedAt     string            `json:"created_at"`
+	Status        TaskStatus        `json:"status"`
+	QueuePosition int               `json:"queue_position,omitempty"`
+	RunAt         string            `json:"run_at,omitempty"`
+	StartedAt     string            `json:"started_at,omitempty"`
+	ExecutedAt    string            `json:"executed_at,omitempty"`
+	AbortedAt     string            `json:"aborted_at,omitempty"`
+	FailedAt      string            `json:"failed_at,omitempty"`
+	InterruptedAt string            `json:"interrupted_at,omitempty"`
+	TimedOutAt    string            `json:"timed_out_at,omitempty"`
+	TimeoutSec    int               `json:"timeout_sec,omitempty"`
+	Deadline      string            `json:"deadline,omitempty"`
+	ExecutionTime string            `json:"execution_time"`
+	Error         string            `json:"error,omitempty"`
+	ErrorCode     string            `json:"error_code,omitempty"`
+	AttemptCount  int               `json:"attempt_count"`
+	Attempts      []TaskAttempt     `json:"attempts,omitempty"`
+	NextAttemptAt string            `json:"next_attempt_at,omitempty"`
+	DependsOn     []int             `json:"depends_on,omitempty"`
+	Priority      int               `json:"priority"`
+	Progress      *TaskProgress     `json:"progress,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	Namespace     string            `json:"namespace"`
+}
+
+type TaskProgress struct {
+	Percent   float64 `json:"percent"`
+	Step      string  `json:"step,omitempty"`
+	Message   string  `json:"message,omitempty"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type TaskAttempt struct {
+	Attempt    int    `json:"attempt"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
 }
 
 // Request header `Endpoint: Tasks.List`
-type ListTasksRequest struct{}
+type ListTasksRequest struct {
+	// Размер страницы, по умолчанию 100, не больше 1000.
+	Limit int `json:"limit,omitempty"`
+	// Курсор следующей страницы из `next_cursor` предыдущего ответа.
+	Cursor   string       `json:"cursor,omitempty"`
+	Status   []TaskStatus `json:"status,omitempty"`
+	TaskType []string     `json:"task_type,omitempty"`
+	// Время создания в формате RFC 3339: от (включительно) и до (не включительно).
+	CreatedFrom string `json:"created_from,omitempty"`
+	CreatedTo   string `json:"created_to,omitempty"`
+	// Метки, которые должны быть у задачи.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Селектор меток, например `env=prod,team!=ml`.
+	LabelSelector string `json:"label_selector,omitempty"`
+	// Только задачи, созданные с ключом API запроса.
+	Mine   bool      `json:"mine,omitempty"`
+	SortBy TaskSort  `json:"sort_by,omitempty"`
+	Order  SortOrder `json:"order,omitempty"`
+}
+
+func (r ListTasksRequest) Validate() error {
+	if r.Limit < 0 || r.Limit > MaxListLimit {
+		return fmt.Errorf("поле `limit` должно быть в диапазоне [0, %d]", MaxListLimit)
+	}
+	for _, status := range r.Status {
+		if !status.valid() {
+			return fmt.Errorf("неизвестный статус задачи `%s`", status)
+		}
+	}
+	for name, value := range map[string]string{"created_from": r.CreatedFrom, "created_to": r.CreatedTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("поле `%s` должно быть в формате RFC 3339: %s", name, err)
+		}
+	}
+	if _, err := labels.Parse(r.LabelSelector); err != nil {
+		return fmt.Errorf("поле `label_selector`: %s", err)
+	}
+	switch r.SortBy {
+	case "", TaskSortCreated, TaskSortPriority:
+	default:
+		return fmt.Errorf("поле `sort_by` должно быть `%s` или `%s`", TaskSortCreated, TaskSortPriority)
+	}
+	switch r.Order {
+	case "", SortOrderAsc, SortOrderDesc:
+	default:
+		return fmt.Errorf("поле `order` должно быть `%s` или `%s`", SortOrderAsc, SortOrderDesc)
+	}
+	return nil
+}
 
 type TaskSummary struct {
-	TaskID   int        `json:"task_id"`
-	TaskType string     `json:"task_type"`
-	Status   TaskStatus `json:"status"`
+	TaskID    int               `json:"task_id"`
+	TaskType  string            `json:"task_type"`
+	Status    TaskStatus        `json:"status"`
+	Priority  int               `json:"priority"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	Namespace string            `json:"namespace"`
 }
 
 type ListTasksResponse struct {
 	Tasks []TaskSummary `json:"tasks"`
+	// Курсор следующей страницы. Отсутствует на последней странице.
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // Request header `Endpoint: Tasks.Cancel`
@@ -115,7 +330,38 @@ func (r GetTaskResultRequest) Validate() error {
 }
 
 type GetTaskResultResponse struct {
-	TaskID int    `json:"task_id"`
-	Result any    `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+	TaskID    int        `json:"task_id"`
+	Status    TaskStatus `json:"status"`
+	Result    any        `json:"result,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	ErrorCode string     `json:"error_code,omitempty"`
+}
+
+const (
+	DefaultWaitTimeoutSec = 30
+	MaxWaitTimeoutSec     = 300
+)
+
+// Request header `Endpoint: Tasks.WaitTask`
+type WaitTaskRequest struct {
+	TaskID int `json:"task_id"`
+	// Сколько ждать завершения задачи, в секундах. По умолчанию 30, не больше 300.
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+}
+
+func (r WaitTaskRequest) Validate() error {
+	if r.TaskID == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `task_id`")
+	}
+	if r.TimeoutSec < 0 || r.TimeoutSec > MaxWaitTimeoutSec {
+		return fmt.Errorf("поле `timeout_sec` должно быть в диапазоне [0, %d]", MaxWaitTimeoutSec)
+	}
+	return nil
+}
+
+type WaitTaskResponse struct {
+	GetTaskDetailsResponse
+	// false - задача не завершилась за отведенное время.
+	Completed bool `json:"completed"`
+	Result    any  `json:"result,omitempty"`
 }
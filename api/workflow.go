@@ -0,0 +1,52 @@
+package api
+
+import "fmt"
+
+// Задача процесса. Зависимости внутри процесса задаются ключами задач,
+// зависимости от уже существующих задач - полем `depends_on`.
+type WorkflowTask struct {
+	Key   string   `json:"key"`
+	After []string `json:"after,omitempty"`
+	CreateTaskRequest
+}
+
+// Request header `Endpoint: Workflows.Create`
+type CreateWorkflowRequest struct {
+	Tasks []WorkflowTask `json:"tasks"`
+}
+
+func (r CreateWorkflowRequest) Validate() error {
+	if len(r.Tasks) == 0 {
+		return fmt.Errorf("тело запроса не содержит поле `tasks`")
+	}
+	keys := make(map[string]bool, len(r.Tasks))
+	for i, task := range r.Tasks {
+		if task.Key == "" {
+			return fmt.Errorf("задача %d: не задано поле `key`", i)
+		}
+		if keys[task.Key] {
+			return fmt.Errorf("задача `%s`: ключ повторяется", task.Key)
+		}
+		keys[task.Key] = true
+		if err := task.CreateTaskRequest.Validate(); err != nil {
+			return fmt.Errorf("задача `%s`: %s", task.Key, err)
+		}
+	}
+	for _, task := range r.Tasks {
+		for _, key := range task.After {
+			if !keys[key] {
+				return fmt.Errorf("задача `%s`: неизвестная зависимость `%s`", task.Key, key)
+			}
+		}
+	}
+	return nil
+}
+
+type WorkflowTaskSummary struct {
+	Key string `json:"key"`
+	TaskSummary
+}
+
+type CreateWorkflowResponse struct {
+	Tasks []WorkflowTaskSummary `json:"tasks"`
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"task-api/internal/gateway"
 	"task-api/pkg/webservice"
 )
 
+// Клиент закрыл соединение, не дождавшись ответа (код nginx).
+const statusClientClosedRequest = 499
+
 func mapError(code webservice.ErrCode, err error) (any, int) {
 	switch code {
 	case webservice.ErrCodeJsonParsing:
@@ -14,6 +19,12 @@ func mapError(code webservice.ErrCode, err error) (any, int) {
 		return wrapMessage(err.Error()), http.StatusBadRequest
 	case webservice.ErrCodeMalformedEndpointHeader, webservice.ErrCodeUnsupportedEndpoint:
 		return wrapMessage(err.Error()), http.StatusBadRequest
+	case webservice.ErrCodeUnauthenticated:
+		return wrapMessage(err.Error()), http.StatusUnauthorized
+	case webservice.ErrCodeForbidden:
+		return wrapMessage(err.Error()), http.StatusForbidden
+	case webservice.ErrCodeRateLimited:
+		return wrapMessage(err.Error()), http.StatusTooManyRequests
 	case webservice.ErrCodeClientCode:
 		if gatErr, ok := err.(*gateway.Error); ok {
 			switch gatErr.Code() {
@@ -21,8 +32,20 @@ func mapError(code webservice.ErrCode, err error) (any, int) {
 				return wrapMessage(err.Error()), http.StatusBadRequest
 			case gateway.ErrCodeNotFound:
 				return wrapMessage(err.Error()), http.StatusNotFound
+			case gateway.ErrCodeUnavailable:
+				return wrapMessage(err.Error()), http.StatusServiceUnavailable
+			case gateway.ErrCodeConflict:
+				return wrapMessage(err.Error()), http.StatusConflict
+			case gateway.ErrCodeForbidden:
+				return wrapMessage(err.Error()), http.StatusForbidden
 			}
 		}
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return wrapMessage("время обработки запроса истекло"), http.StatusGatewayTimeout
+		case errors.Is(err, context.Canceled):
+			return wrapMessage("запрос отменен"), statusClientClosedRequest
+		}
 	}
 	return wrapMessage("что-то пошло не так"), http.StatusInternalServerError
 }
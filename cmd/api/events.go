@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"task-api/internal/events"
+	"task-api/internal/repository"
+	"task-api/pkg/webservice"
+	"time"
+)
+
+// Интервал комментариев-пингов, не дающих прокси закрыть простаивающее соединение.
+const eventsHeartbeat = 15 * time.Second
+
+// Имя, под которым поток событий указывается в разрешенных эндпоинтах ключа API.
+const eventsEndpoint = "Events.Stream"
+
+// Поток событий задач в формате Server-Sent Events.
+// Параметры запроса `task_id` и `task_type` (можно повторять) отбирают события.
+// Номер последнего полученного события передается в заголовке `Last-Event-ID`
+// или в параметре `last_event_id`. Поток содержит только события задач пространства
+// имен запроса (администратору без X-Namespace - всех пространств), а с ключами API
+// (keys != nil) - только задач, доступных ключу.
+func streamEvents(bus *events.Bus, keys *webservice.APIKeys) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "потоковая передача не поддерживается", http.StatusInternalServerError)
+			return
+		}
+		var principal *webservice.Principal
+		if keys != nil {
+			var err error
+			if principal, err = keys.Authenticate(r); err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSONError(w, webservice.ErrCodeUnauthenticated, err)
+				return
+			}
+			if !principal.AllowsEndpoint(eventsEndpoint) {
+				err := fmt.Errorf("ключу API `%s` не разрешен эндпоинт `%s`", principal.Name, eventsEndpoint)
+				writeJSONError(w, webservice.ErrCodeForbidden, err)
+				return
+			}
+		}
+		ns, err := resolveNamespace(r, principal)
+		if err != nil {
+			writeJSONError(w, webservice.ErrCodeClientCode, err)
+			return
+		}
+		ctx := repository.WithNamespace(r.Context(), ns)
+		if principal != nil && principal.Admin && r.Header.Get(namespaceHeader) == "" {
+			ctx = repository.WithAllNamespaces(ctx)
+		}
+		filter, err := eventsFilter(ctx, r, principal)
+		if err != nil {
+			writeJSONError(w, webservice.ErrCodeJsonBodyValidation, err)
+			return
+		}
+		lastID, err := lastEventID(r)
+		if err != nil {
+			writeJSONError(w, webservice.ErrCodeJsonBodyValidation, err)
+			return
+		}
+
+		backlog, ch, cancel := bus.Subscribe(lastID, filter)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		for _, e := range backlog {
+			writeEvent(w, e)
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case e, ok := <-ch:
+				if !ok {
+					// Клиент не успевал читать события или сервис останавливается:
+					// клиент должен переподключиться.
+					return
+				}
+				writeEvent(w, e)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+func writeJSONError(w http.ResponseWriter, code webservice.ErrCode, err error) {
+	rsp, status := mapError(code, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func eventsFilter(ctx context.Context, r *http.Request, principal *webservice.Principal) (events.Filter, error) {
+	query := r.URL.Query()
+	var taskIDs []uint64
+	for _, raw := range query["task_id"] {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("параметр `task_id` должен быть целым числом: %s", raw)
+		}
+		taskIDs = append(taskIDs, id)
+	}
+	taskTypes := query["task_type"]
+	ns, limited := repository.NamespaceFromContext(ctx)
+	return func(e events.Event) bool {
+		if limited && e.Namespace != ns {
+			return false
+		}
+		if len(taskIDs) > 0 && !slices.Contains(taskIDs, e.TaskID) {
+			return false
+		}
+		if len(taskTypes) > 0 && !slices.Contains(taskTypes, e.TaskType) {
+			return false
+		}
+		if principal != nil {
+			if !principal.AllowsTaskType(e.TaskType) {
+				return false
+			}
+			if principal.OwnTasksOnly && e.Owner != principal.Name {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func lastEventID(r *http.Request) (uint64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("номер последнего события должен быть целым числом: %s", raw)
+	}
+	return id, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"task-api/internal/repository"
+	"time"
+)
+
+// Ограничение времени одной проверки состояния.
+const healthCheckTimeout = 2 * time.Second
+
+// Проверка компонента сервиса. nil - компонент в порядке.
+type healthCheck func(ctx context.Context) error
+
+// Состояние сервиса для оркестратора: /healthz - жив ли процесс,
+// /readyz - можно ли направлять в него запросы.
+type health struct {
+	repo repository.Repository
+	// Обработка результатов исполнителя, например operator.Health.
+	results  healthCheck
+	draining atomic.Bool
+}
+
+// Переводит сервис в режим остановки: /readyz отвечает 503,
+// чтобы оркестратор перестал направлять в него запросы.
+func (h *health) drain() {
+	h.draining.Store(true)
+}
+
+// Живость: результаты задач обрабатываются. Зависший обработчик лечится перезапуском.
+func (h *health) liveness() http.HandlerFunc {
+	return h.handler(map[string]healthCheck{
+		"results": h.results,
+	})
+}
+
+// Готовность: хранилище доступно, результаты задач обрабатываются, сервис не останавливается.
+func (h *health) readiness() http.HandlerFunc {
+	return h.handler(map[string]healthCheck{
+		"repository": h.checkRepository,
+		"results":    h.results,
+		"draining": func(context.Context) error {
+			if h.draining.Load() {
+				return errors.New("сервис останавливается")
+			}
+			return nil
+		},
+	})
+}
+
+// Хранилище доступно, если отвечает на поиск задачи. Задачи с id 0 не бывает.
+func (h *health) checkRepository(ctx context.Context) error {
+	_, err := h.repo.Find(repository.WithAllNamespaces(ctx), 0)
+	var repoErr *repository.Error
+	if errors.As(err, &repoErr) && repoErr.Code() == repository.ErrCodeNotFound {
+		return nil
+	}
+	return err
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (h *health) handler(checks map[string]healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := healthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+		for name, check := range checks {
+			res.Checks[name] = "ok"
+			if err := runCheck(r.Context(), check); err != nil {
+				res.Checks[name] = err.Error()
+				res.Status = "unavailable"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if res.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// Выполняет проверку не дольше healthCheckTimeout, даже если она не реагирует на контекст.
+func runCheck(ctx context.Context, check healthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("проверка не завершилась за %s", healthCheckTimeout)
+	}
+}
@@ -1,36 +1,261 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"task-api/api"
+	"task-api/internal/events"
 	"task-api/internal/executor"
 	"task-api/internal/factory"
 	"task-api/internal/gateway"
+	"task-api/internal/monitoring"
 	"task-api/internal/operator"
 	"task-api/internal/repository"
+	"task-api/internal/scheduler"
+	"task-api/pkg/metrics"
 	"task-api/pkg/webservice"
+	"time"
 )
 
 func main() {
-	repo := repository.New()
-	oper := operator.New(repo, executor.New())
-	gat := gateway.New(repo, oper, factory.New())
+	workers := flag.Int("workers", runtime.NumCPU(), "число одновременно выполняемых задач")
+	queueSize := flag.Int("queue-size", 0, "максимальное число задач в очереди (0 - без ограничения)")
+	storage := flag.String("storage", "memory", "хранилище задач: memory или file")
+	dataDir := flag.String("data-dir", "./data", "каталог файлового хранилища")
+	aging := flag.Duration("priority-aging", 10*time.Second, "время ожидания в очереди, за которое приоритет задачи растет на 1 (0 - без старения)")
+	eventsCapacity := flag.Int("events-capacity", 1024, "число последних событий, доступных для возобновления потока")
+	idempotencyTTL := flag.Duration("idempotency-ttl", 24*time.Hour, "время хранения ключей идемпотентности")
+	apiKeysPath := flag.String("api-keys", "", "файл ключей API (пусто - API без аутентификации)")
+	typeQuotas := flag.String("task-type-quotas", "", "максимум одновременно выполняемых задач по типам, например waiting=5,report=2")
+	rateLimit := flag.String("rate-limit", "", "ограничение частоты запросов клиента к эндпоинту: запросов в секунду[:всплеск] (пусто - без ограничения)")
+	endpointRates := flag.String("endpoint-rate-limits", "", "ограничения частоты запросов отдельных эндпоинтов, например Tasks.CreateTask=1:5,Tasks.GetTaskDetails=50")
+	requestTimeout := flag.Duration("request-timeout", 30*time.Second, "ограничение времени обработки запроса (0 - без ограничения)")
+	shutdownGrace := flag.Duration("shutdown-grace", 30*time.Second, "время на завершение выполняемых задач при остановке сервиса")
+	flag.Parse()
+
+	store, err := newRepository(*storage, *dataDir)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	var keys *webservice.APIKeys
+	if *apiKeysPath != "" {
+		if keys, err = webservice.LoadAPIKeys(*apiKeysPath); err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+	quotas, err := quotaOptions(*typeQuotas, keys)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	limiter, err := rateLimiter(*rateLimit, *endpointRates, keys)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	bus := events.NewBus(events.WithCapacity(*eventsCapacity))
+	reg := metrics.NewRegistry()
+	repo := monitoring.Observe(events.Observe(store, bus), reg)
+	exec := executor.New(append([]executor.Option{
+		executor.WithWorkers(*workers),
+		executor.WithQueueSize(*queueSize),
+		executor.WithAging(*aging),
+	}, quotas...)...)
+	monitoring.ObserveLoad(reg, exec)
+	fact := factory.New()
+	oper := operator.New(repo, exec, operator.WithConstructor(fact))
+	gat := gateway.New(repo, oper, fact, gateway.WithIdempotencyTTL(*idempotencyTTL))
+	schedules := scheduler.New(oper, fact)
+	sched := gateway.NewSchedules(schedules, repo)
+	flows := gateway.NewWorkflows(oper, fact)
+	adm := gateway.NewAdmin(repo)
+	quot := gateway.NewQuotas(oper)
+	hc := &health{repo: repo, results: oper.Health}
 
 	s := webservice.New()
+	s.Use(
+		webservice.RequestID(),
+		webservice.RequestMetrics(reg),
+		webservice.AccessLog(slog.Default()),
+		webservice.Recovery(slog.Default()),
+	)
+	// Ограничение частоты до проверки ключа: запросы с неверным ключом тоже ограничиваются.
+	if limiter != nil {
+		s.Use(webservice.RateLimit(limiter))
+	}
+	if keys != nil {
+		s.Use(webservice.APIKeyAuth(keys))
+	}
+	s.Use(namespaces())
 	webservice.Register(s, "Tasks.CreateTask", gat.CreateTask)
 	webservice.Register(s, "Tasks.ListTasks", gat.ListTasks)
 	webservice.Register(s, "Tasks.CancelTask", gat.CancelTask)
 	webservice.Register(s, "Tasks.DeleteTask", gat.DeleteTask)
 	webservice.Register(s, "Tasks.GetTaskResult", gat.GetTaskResult)
 	webservice.Register(s, "Tasks.GetTaskDetails", gat.GetTaskDetails)
+	// Ожидание ограничено полем `timeout_sec`, а не временем обработки запроса.
+	waitTimeout := time.Duration(api.MaxWaitTimeoutSec)*time.Second + *requestTimeout
+	webservice.Register(s, "Tasks.WaitTask", gat.WaitTask, webservice.WithTimeout(waitTimeout))
+	webservice.Register(s, "Tasks.CreateTasks", gat.CreateTasks)
+	webservice.Register(s, "Tasks.CancelTasks", gat.CancelTasks)
+	webservice.Register(s, "Tasks.DeleteTasks", gat.DeleteTasks)
+	webservice.Register(s, "Schedules.Create", sched.Create)
+	webservice.Register(s, "Schedules.List", sched.List)
+	webservice.Register(s, "Schedules.Pause", sched.Pause)
+	webservice.Register(s, "Schedules.Resume", sched.Resume)
+	webservice.Register(s, "Schedules.Delete", sched.Delete)
+	webservice.Register(s, "Schedules.ListRuns", sched.ListRuns)
+	webservice.Register(s, "Workflows.Create", flows.Create)
+	webservice.Register(s, "Quotas.Get", quot.Get)
+	webservice.Register(s, "Admin.ListTasks", adm.ListTasks)
+	webservice.Register(s, "Admin.ListNamespaces", adm.ListNamespaces)
 
 	s.WithErrorMapper(mapError)
+	s.WithDefaultTimeout(*requestTimeout)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /api", s.Handle)
+	mux.HandleFunc("GET /events", streamEvents(bus, keys))
+	mux.HandleFunc("GET /metrics", reg.Handler())
+	mux.HandleFunc("GET /healthz", hc.liveness())
+	mux.HandleFunc("GET /readyz", hc.readiness())
 
-	err := http.ListenAndServe(":8080", mux)
-	if err != nil {
-		slog.Error(err.Error())
+	srv := &http.Server{Addr: ":8080", Handler: mux}
+	// Потоки событий не завершаются сами, поэтому закрываются при остановке сервера.
+	srv.RegisterOnShutdown(bus.Close)
+	go func() {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+	stop()
+	// Остановка: /readyz отвечает 503, новые задачи не принимаются, выполняемые
+	// задачи завершаются за shutdown-grace, остальные прерываются.
+	slog.Info("остановка сервиса", "grace", *shutdownGrace)
+	hc.drain()
+	schedules.Stop()
+	graceCtx, cancel := context.WithTimeout(context.Background(), *shutdownGrace)
+	defer cancel()
+	if err := oper.Shutdown(graceCtx); err != nil {
+		slog.Error("ошибка остановки задач", "error", err.Error())
+	}
+	srvCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(srvCtx); err != nil {
+		slog.Error("ошибка остановки HTTP-сервера", "error", err.Error())
+	}
+	if c, ok := store.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			slog.Error("ошибка закрытия хранилища", "error", err.Error())
+		}
+	}
+	slog.Info("сервис остановлен")
+}
+
+func newRepository(storage, dataDir string) (repository.Repository, error) {
+	switch storage {
+	case "memory":
+		return repository.New(), nil
+	case "file":
+		return repository.NewFile(dataDir)
+	}
+	return nil, fmt.Errorf("неизвестный тип хранилища: %s", storage)
+}
+
+// Квоты исполнителя: по типам задач из значения флага `тип=число,...`
+// и по ключам API с `max_running`.
+func quotaOptions(typeQuotas string, keys *webservice.APIKeys) ([]executor.Option, error) {
+	var opts []executor.Option
+	for _, item := range strings.Split(typeQuotas, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		taskType, value, ok := strings.Cut(item, "=")
+		limit, err := strconv.Atoi(value)
+		if !ok || taskType == "" || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("неверная квота типа задачи `%s`: ожидается `тип=число`", item)
+		}
+		key := executor.QuotaKey{Scope: executor.QuotaScopeTaskType, Name: taskType}
+		opts = append(opts, executor.WithQuota(key, limit))
+	}
+	if keys != nil {
+		for _, p := range keys.Principals() {
+			if p.MaxRunning > 0 {
+				key := executor.QuotaKey{Scope: executor.QuotaScopeOwner, Name: p.Name}
+				opts = append(opts, executor.WithQuota(key, p.MaxRunning))
+			}
+		}
+	}
+	return opts, nil
+}
+
+// Ограничитель частоты запросов из значений флагов. nil - ограничений нет.
+func rateLimiter(rate, endpointRates string, keys *webservice.APIKeys) (*webservice.RateLimiter, error) {
+	var def webservice.Rate
+	if rate != "" {
+		var err error
+		if def, err = parseRate(rate); err != nil {
+			return nil, err
+		}
+	}
+	var opts []webservice.RateLimitOption
+	for _, item := range strings.Split(endpointRates, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		endpoint, value, ok := strings.Cut(item, "=")
+		if !ok || endpoint == "" {
+			return nil, fmt.Errorf("неверное ограничение частоты запросов `%s`: ожидается `эндпоинт=запросов в секунду[:всплеск]`", item)
+		}
+		r, err := parseRate(value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, webservice.WithEndpointRate(endpoint, r))
+	}
+	if rate == "" && len(opts) == 0 {
+		return nil, nil
+	}
+	if keys != nil {
+		opts = append(opts, webservice.WithAPIKeys(keys))
+	}
+	return webservice.NewRateLimiter(def, opts...), nil
+}
+
+// Разбирает ограничение частоты вида `запросов в секунду[:всплеск]`.
+// По умолчанию всплеск равен числу запросов в секунду, но не меньше 1.
+func parseRate(s string) (webservice.Rate, error) {
+	limit, burst, hasBurst := strings.Cut(s, ":")
+	r := webservice.Rate{}
+	var err error
+	if r.Limit, err = strconv.ParseFloat(limit, 64); err != nil || r.Limit <= 0 {
+		return r, fmt.Errorf("неверное ограничение частоты запросов `%s`: число запросов в секунду должно быть положительным", s)
+	}
+	r.Burst = max(int(math.Ceil(r.Limit)), 1)
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(burst); err != nil || r.Burst <= 0 {
+			return r, fmt.Errorf("неверное ограничение частоты запросов `%s`: всплеск должен быть положительным целым", s)
+		}
 	}
+	return r, nil
 }
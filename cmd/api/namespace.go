@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"task-api/internal/gateway"
+	"task-api/internal/repository"
+	"task-api/pkg/webservice"
+)
+
+const namespaceHeader = "X-Namespace"
+
+// Ограничивает запрос пространством имен из заголовка X-Namespace или ключа API.
+func namespaces() webservice.Middleware {
+	return func(next webservice.Handler) webservice.Handler {
+		return func(ctx context.Context, call *webservice.Call) error {
+			p, _ := webservice.PrincipalFromContext(ctx)
+			ns, err := resolveNamespace(call.HTTPRequest, p)
+			if err != nil {
+				return err
+			}
+			return next(repository.WithNamespace(ctx, ns), call)
+		}
+	}
+}
+
+// Пространство имен запроса. Ключ, если он не администраторский, работает только
+// в своем пространстве, а ключ без пространства - в пространстве по умолчанию.
+func resolveNamespace(r *http.Request, p *webservice.Principal) (string, error) {
+	ns := r.Header.Get(namespaceHeader)
+	if p != nil && !p.Admin {
+		own := p.Namespace
+		if own == "" {
+			own = repository.DefaultNamespace
+		}
+		if ns != "" && ns != own {
+			msg := fmt.Sprintf("ключу API `%s` не разрешено пространство имен `%s`", p.Name, ns)
+			return "", gateway.NewError(gateway.ErrCodeForbidden, msg)
+		}
+		ns = own
+	}
+	if ns == "" {
+		return repository.DefaultNamespace, nil
+	}
+	if !validNamespace(ns) {
+		msg := fmt.Sprintf("неверное пространство имен `%s`: до 63 строчных латинских букв, цифр и `-`", ns)
+		return "", gateway.NewError(gateway.ErrCodeBadInput, msg)
+	}
+	return ns, nil
+}
+
+func validNamespace(ns string) bool {
+	if len(ns) > 63 || ns[0] == '-' || ns[len(ns)-1] == '-' {
+		return false
+	}
+	for _, c := range ns {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
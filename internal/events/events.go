@@ -0,0 +1,170 @@
+package events
+
+import (
+	"sync"
+	"task-api/pkg/timing"
+)
+
+type Type string
+
+// Типы событий. Переход задачи в остальные статусы (`retrying`, `timed_out`,
+// `scheduled` и т.д.) публикуется с типом, равным статусу.
+const (
+	TypeCreated  Type = "created"
+	TypeStarted  Type = "started"
+	TypeProgress Type = "progress"
+	TypeExecuted Type = "executed"
+	TypeFailed   Type = "failed"
+	TypeAborted  Type = "aborted"
+	TypeDeleted  Type = "deleted"
+)
+
+// Событие жизненного цикла задачи.
+type Event struct {
+	ID        uint64 `json:"id"`
+	Type      Type   `json:"type"`
+	TaskID    uint64 `json:"task_id"`
+	TaskType  string `json:"task_type"`
+	Owner     string `json:"owner,omitempty"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status,omitempty"`
+	Timestamp int64  `json:"-"`
+	Time      string `json:"time"`
+	// Прогресс для событий `progress`, ошибка для неуспешного завершения.
+	Progress  *Progress `json:"progress,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode string    `json:"error_code,omitempty"`
+}
+
+type Progress struct {
+	Percent float64 `json:"percent"`
+	Step    string  `json:"step,omitempty"`
+	Message string  `json:"message,omitempty"`
+}
+
+// Отбирает события для подписчика.
+type Filter func(e Event) bool
+
+const (
+	defaultCapacity   = 1024
+	subscriberBufSize = 64
+)
+
+type Option func(b *Bus)
+
+// Число последних событий, доступных для возобновления подписки.
+func WithCapacity(n int) Option {
+	return func(b *Bus) {
+		b.capacity = n
+	}
+}
+
+type subscriber struct {
+	ch     chan Event
+	filter Filter
+}
+
+// Шина событий. Хранит последние события в кольцевом буфере,
+// чтобы подписчик мог продолжить с места обрыва соединения.
+type Bus struct {
+	capacity int
+
+	mu     sync.Mutex
+	ring   []Event
+	nextID uint64
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+func NewBus(opts ...Option) *Bus {
+	b := &Bus{
+		capacity: defaultCapacity,
+		nextID:   1,
+		subs:     make(map[*subscriber]struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.capacity <= 0 {
+		b.capacity = 1
+	}
+	b.ring = make([]Event, b.capacity)
+	return b
+}
+
+// Присваивает событию номер, сохраняет его и рассылает подписчикам.
+// Подписчик, не успевающий читать события, отключается: он может
+// переподключиться, передав номер последнего полученного события.
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.ID = b.nextID
+	b.nextID++
+	if e.Timestamp == 0 {
+		e.Timestamp = timing.Timestamp()
+	}
+	e.Time = timing.Format(e.Timestamp)
+	b.ring[e.ID%uint64(b.capacity)] = e
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			close(sub.ch)
+			delete(b.subs, sub)
+		}
+	}
+}
+
+// Подписывается на события после события с номером lastID (0 - только новые).
+// Возвращает сохраненные события после lastID и канал новых событий.
+// Канал закрывается при отписке через cancel или при переполнении.
+func (b *Bus) Subscribe(lastID uint64, filter Filter) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var backlog []Event
+	if lastID > 0 {
+		oldest := uint64(1)
+		if b.nextID > uint64(b.capacity) {
+			oldest = b.nextID - uint64(b.capacity)
+		}
+		for id := max(lastID+1, oldest); id < b.nextID; id++ {
+			e := b.ring[id%uint64(b.capacity)]
+			if filter == nil || filter(e) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+	sub := &subscriber{
+		ch:     make(chan Event, subscriberBufSize),
+		filter: filter,
+	}
+	if b.closed {
+		close(sub.ch)
+		return backlog, sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			close(sub.ch)
+			delete(b.subs, sub)
+		}
+	}
+	return backlog, sub.ch, cancel
+}
+
+// Закрывает каналы всех подписчиков, например при остановке сервиса.
+// Новые подписчики получают только сохраненные события и закрытый канал.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"task-api/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusResume(t *testing.T) {
+	bus := NewBus(WithCapacity(3))
+	for i := range 5 {
+		bus.Publish(Event{Type: TypeCreated, TaskID: uint64(i + 1)})
+	}
+
+	// В буфере остались события 3-5.
+	backlog, _, cancel := bus.Subscribe(1, nil)
+	defer cancel()
+	assert.Len(t, backlog, 3)
+	assert.Equal(t, backlog[0].ID, uint64(3))
+
+	backlog, _, cancel = bus.Subscribe(4, nil)
+	defer cancel()
+	assert.Len(t, backlog, 1)
+	assert.Equal(t, backlog[0].ID, uint64(5))
+
+	backlog, _, cancel = bus.Subscribe(0, nil)
+	defer cancel()
+	assert.Empty(t, backlog)
+}
+
+func TestBusFilter(t *testing.T) {
+	bus := NewBus()
+	_, ch, cancel := bus.Subscribe(0, func(e Event) bool { return e.TaskID == 2 })
+	bus.Publish(Event{Type: TypeCreated, TaskID: 1})
+	bus.Publish(Event{Type: TypeCreated, TaskID: 2})
+	e := <-ch
+	assert.Equal(t, e.TaskID, uint64(2))
+	assert.Equal(t, e.ID, uint64(2))
+	assert.NotEmpty(t, e.Time)
+
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	_, ch, cancel := bus.Subscribe(0, nil)
+	defer cancel()
+	for range subscriberBufSize + 1 {
+		bus.Publish(Event{Type: TypeCreated})
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	assert.Equal(t, n, subscriberBufSize)
+}
+
+func TestBusClose(t *testing.T) {
+	bus := NewBus()
+	_, ch, cancel := bus.Subscribe(0, nil)
+	defer cancel()
+	bus.Close()
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	_, ch, cancel = bus.Subscribe(0, nil)
+	cancel()
+	_, ok = <-ch
+	assert.False(t, ok)
+}
+
+func TestObservedRepository(t *testing.T) {
+	bus := NewBus()
+	repo := Observe(repository.New(), bus)
+	ctx := context.Background()
+	_, ch, cancel := bus.Subscribe(0, nil)
+	defer cancel()
+
+	task, _ := repo.Create(ctx, repository.Task{Type: "test", Status: repository.StatusQueued})
+	repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusRunning
+		return t, nil
+	})
+	repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Progress = &repository.Progress{Percent: 50}
+		return t, nil
+	})
+	repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusFailed
+		t.Error = "error"
+		return t, nil
+	})
+	assert.NoError(t, repo.Delete(ctx, task.ID))
+
+	var types []Type
+	for range 5 {
+		e := <-ch
+		assert.Equal(t, e.TaskID, task.ID)
+		assert.Equal(t, e.TaskType, "test")
+		if e.Type == TypeFailed {
+			assert.Equal(t, e.Error, "error")
+		}
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, types, []Type{TypeCreated, TypeStarted, TypeProgress, TypeFailed, TypeDeleted})
+}
+
+// Хранилище, не сохраняющее изменения задач, например из-за ошибки записи журнала.
+type failingRepository struct {
+	repository.Repository
+}
+
+// Update implements repository.Repository.
+func (r failingRepository) Update(ctx context.Context, taskID uint64, update func(t repository.Task) (repository.Task, error)) (*repository.Task, error) {
+	task, err := r.Find(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := update(*task); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("запись журнала не удалась")
+}
+
+func TestObservedRepositoryFailedUpdate(t *testing.T) {
+	bus := NewBus()
+	repo := Observe(failingRepository{repository.New()}, bus)
+	ctx := context.Background()
+	task, _ := repo.Create(ctx, repository.Task{Type: "test", Status: repository.StatusRunning})
+	_, ch, cancel := bus.Subscribe(0, nil)
+	defer cancel()
+
+	// Несохраненное изменение не публикуется.
+	_, err := repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusExecuted
+		return t, nil
+	})
+	assert.Error(t, err)
+	select {
+	case e := <-ch:
+		t.Fatalf("неожиданное событие %s", e.Type)
+	default:
+	}
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"task-api/internal/repository"
+)
+
+// Хранилище, публикующее события при изменении задач.
+type observedRepository struct {
+	repository.Repository
+	bus *Bus
+
+	mu sync.Mutex
+	// Блокировки задач: изменение и публикация события не пересекаются
+	// с другими изменениями задачи, поэтому события задачи идут по порядку.
+	locks map[uint64]*taskLock
+}
+
+type taskLock struct {
+	sync.Mutex
+	refs int
+}
+
+var _ repository.Repository = (*observedRepository)(nil)
+
+// Оборачивает хранилище r: события выводятся из изменений задач,
+// поэтому публикуются при любом пути изменения статуса.
+func Observe(r repository.Repository, b *Bus) repository.Repository {
+	return &observedRepository{Repository: r, bus: b, locks: make(map[uint64]*taskLock)}
+}
+
+// Create implements repository.Repository.
+func (r *observedRepository) Create(ctx context.Context, task repository.Task) (*repository.Task, error) {
+	created, err := r.Repository.Create(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	r.bus.Publish(taskEvent(TypeCreated, *created))
+	return created, nil
+}
+
+// Update implements repository.Repository.
+func (r *observedRepository) Update(ctx context.Context, taskID uint64, update func(t repository.Task) (repository.Task, error)) (*repository.Task, error) {
+	unlock := r.lock(taskID)
+	defer unlock()
+	var old repository.Task
+	t, err := r.Repository.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
+		old = t
+		return update(t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Публикуем только сохраненные изменения.
+	if t.Status != old.Status {
+		r.bus.Publish(taskEvent(statusEventType(t.Status), *t))
+	} else if t.Progress != nil && t.Progress != old.Progress {
+		r.bus.Publish(taskEvent(TypeProgress, *t))
+	}
+	return t, nil
+}
+
+// Блокирует изменения задачи taskID до вызова unlock.
+func (r *observedRepository) lock(taskID uint64) (unlock func()) {
+	r.mu.Lock()
+	l, ok := r.locks[taskID]
+	if !ok {
+		l = &taskLock{}
+		r.locks[taskID] = l
+	}
+	l.refs++
+	r.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		r.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(r.locks, taskID)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Delete implements repository.Repository.
+func (r *observedRepository) Delete(ctx context.Context, taskID uint64) error {
+	task, findErr := r.Repository.Find(ctx, taskID)
+	if err := r.Repository.Delete(ctx, taskID); err != nil {
+		return err
+	}
+	e := Event{Type: TypeDeleted, TaskID: taskID}
+	if findErr == nil {
+		e.TaskType = task.Type
+		e.Owner = task.Owner
+		e.Namespace = task.NamespaceOrDefault()
+	}
+	r.bus.Publish(e)
+	return nil
+}
+
+func statusEventType(s repository.Status) Type {
+	if s == repository.StatusRunning {
+		return TypeStarted
+	}
+	return Type(s)
+}
+
+func taskEvent(typ Type, t repository.Task) Event {
+	e := Event{
+		Type:      typ,
+		TaskID:    t.ID,
+		TaskType:  t.Type,
+		Owner:     t.Owner,
+		Namespace: t.NamespaceOrDefault(),
+		Status:    string(t.Status),
+	}
+	switch typ {
+	case TypeProgress:
+		e.Progress = &Progress{
+			Percent: t.Progress.Percent,
+			Step:    t.Progress.Step,
+			Message: t.Progress.Message,
+		}
+	case TypeCreated, TypeStarted, TypeExecuted:
+	default:
+		e.Error = t.Error
+		e.ErrorCode = t.ErrorCode
+	}
+	return e
+}
@@ -4,6 +4,9 @@ type ErrCode int
 
 const (
 	ErrCodeBadInput ErrCode = iota
+	ErrCodeQueueFull
+	// Исполнитель остановлен и не принимает задачи.
+	ErrCodeStopped
 )
 
 type Error struct {
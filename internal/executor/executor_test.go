@@ -39,3 +39,235 @@ func TestExecutor(t *testing.T) {
 	assert.Equal(t, result.Data, nil)
 	assert.Equal(t, result.Error.Error(), "error")
 }
+
+func TestExecutorPriority(t *testing.T) {
+	exec := New(WithWorkers(1), WithAging(20*time.Millisecond))
+	ctx := context.Background()
+	running := blockingTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 1, running))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 1)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, exec.Execute(ctx, 2, successTask{}))
+	assert.NoError(t, exec.Execute(ctx, 3, successTask{}, WithPriority(1)))
+	pos, _ := exec.QueuePosition(ctx, 3)
+	assert.Equal(t, pos, 1)
+	pos, _ = exec.QueuePosition(ctx, 2)
+	assert.Equal(t, pos, 2)
+
+	// Задача 2 ждет дольше: старение поднимает ее приоритет выше приоритета новой задачи 4.
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, exec.Execute(ctx, 4, successTask{}, WithPriority(2)))
+
+	close(running.release)
+	var order []uint64
+	for range 4 {
+		order = append(order, (<-exec.Results(ctx)).TaskID)
+	}
+	assert.Equal(t, order, []uint64{1, 3, 2, 4})
+}
+
+type blockingTask struct {
+	release chan struct{}
+}
+
+func (b blockingTask) Execute(ctx context.Context) (any, error) {
+	select {
+	case <-b.release:
+		return 42, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestExecutorQueue(t *testing.T) {
+	exec := New(WithWorkers(1), WithQueueSize(2))
+	ctx := context.Background()
+	running := blockingTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 1, running))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 1)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, exec.Execute(ctx, 2, successTask{}))
+	assert.NoError(t, exec.Execute(ctx, 3, successTask{}))
+	pos, ok := exec.QueuePosition(ctx, 3)
+	assert.True(t, ok)
+	assert.Equal(t, pos, 2)
+
+	err := exec.Execute(ctx, 4, successTask{})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeQueueFull)
+
+	assert.NoError(t, exec.Cancel(ctx, 2))
+	pos, ok = exec.QueuePosition(ctx, 3)
+	assert.True(t, ok)
+	assert.Equal(t, pos, 1)
+
+	close(running.release)
+	result := <-exec.Results(ctx)
+	assert.Equal(t, result.TaskID, uint64(1))
+	result = <-exec.Results(ctx)
+	assert.Equal(t, result.TaskID, uint64(3))
+}
+
+func TestExecutorQuota(t *testing.T) {
+	waiting := QuotaKey{Scope: QuotaScopeTaskType, Name: "waiting"}
+	waitingA := QuotaKey{Scope: QuotaScopeTaskType, Name: "waiting", Namespace: "a"}
+	waitingB := QuotaKey{Scope: QuotaScopeTaskType, Name: "waiting", Namespace: "b"}
+	exec := New(WithWorkers(3), WithQuota(waiting, 1))
+	ctx := context.Background()
+	first := blockingTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 1, first, WithQuotaKeys(waitingA)))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 1)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+
+	// Задача сверх квоты остается в очереди, задачи того же типа
+	// из другого пространства имен выполняются.
+	assert.NoError(t, exec.Execute(ctx, 2, blockingTask{make(chan struct{})}, WithQuotaKeys(waitingA)))
+	other := blockingTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 3, other, WithQuotaKeys(waitingB)))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 3)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+	_, queued := exec.QueuePosition(ctx, 2)
+	assert.True(t, queued)
+	assert.Equal(t, exec.Quotas(ctx, "a"), []Quota{{QuotaKey: waitingA, Limit: 1, Running: 1, Queued: 1}})
+	assert.Equal(t, exec.Quotas(ctx, "b"), []Quota{{QuotaKey: waitingB, Limit: 1, Running: 1}})
+	assert.Equal(t, exec.Quotas(ctx, "c"), []Quota{{QuotaKey: QuotaKey{Scope: QuotaScopeTaskType, Name: "waiting", Namespace: "c"}, Limit: 1}})
+
+	close(first.release)
+	assert.Equal(t, (<-exec.Results(ctx)).TaskID, uint64(1))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 2)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, exec.Quotas(ctx, "a"), []Quota{{QuotaKey: waitingA, Limit: 1, Running: 1}})
+}
+
+func TestExecutorResultsBacklog(t *testing.T) {
+	exec := New(WithWorkers(1))
+	ctx := context.Background()
+	assert.NoError(t, exec.Execute(ctx, 1, panicTask{}))
+	// Результат ждет получателя, пока его не прочитают из Results.
+	assert.Eventually(t, func() bool {
+		n, _ := exec.ResultsBacklog(ctx)
+		return n == 1
+	}, time.Second, 5*time.Millisecond)
+	<-exec.Results(ctx)
+	assert.Eventually(t, func() bool {
+		n, age := exec.ResultsBacklog(ctx)
+		return n == 0 && age == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestExecutorShutdown(t *testing.T) {
+	exec := New(WithWorkers(1))
+	ctx := context.Background()
+	finishing := blockingTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 1, finishing))
+	assert.Eventually(t, func() bool {
+		_, queued := exec.QueuePosition(ctx, 1)
+		return !queued
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, exec.Execute(ctx, 2, successTask{}))
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		close(finishing.release)
+	}()
+	done := make(chan struct{})
+	go func() {
+		exec.Shutdown(ctx)
+		close(done)
+	}()
+	// Выполняемая задача завершается и отдает результат, задача из очереди убирается.
+	assert.Equal(t, (<-exec.Results(ctx)).TaskID, uint64(1))
+	<-done
+	_, ok := <-exec.Results(ctx)
+	assert.False(t, ok)
+
+	err := exec.Execute(ctx, 3, successTask{})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeStopped)
+}
+
+type panicTask struct{}
+
+func (p panicTask) Execute(context.Context) (any, error) {
+	panic("boom")
+}
+
+func TestExecutorFailureCode(t *testing.T) {
+	exec := New(WithWorkers(1))
+	ctx := context.Background()
+	err := exec.Execute(ctx, 1, panicTask{})
+	assert.NoError(t, err)
+	result := <-exec.Results(ctx)
+	assert.Error(t, result.Error)
+	assert.Equal(t, FailureCode(result.Error), FailureCodePanic)
+
+	assert.Equal(t, FailureCode(fmt.Errorf("error")), FailureCodeFailed)
+	wrapped := fmt.Errorf("wrapped: %w", NewFailure("custom", fmt.Errorf("error")))
+	assert.Equal(t, FailureCode(wrapped), "custom")
+}
+
+func TestExecutorDeadline(t *testing.T) {
+	exec := New(WithWorkers(1))
+	ctx := context.Background()
+	task := blockingTask{make(chan struct{})}
+	err := exec.Execute(ctx, 1, task, WithTimeout(10*time.Millisecond))
+	assert.NoError(t, err)
+	result := <-exec.Results(ctx)
+	assert.Equal(t, FailureCode(result.Error), FailureCodeTimeout)
+
+	err = exec.Execute(ctx, 2, successTask{}, WithDeadline(time.Now().Add(-time.Second)))
+	assert.NoError(t, err)
+	result = <-exec.Results(ctx)
+	assert.Equal(t, FailureCode(result.Error), FailureCodeTimeout)
+}
+
+// Задача, не реагирующая на отмену контекста.
+type stubbornTask struct {
+	release chan struct{}
+}
+
+func (s stubbornTask) Execute(context.Context) (any, error) {
+	<-s.release
+	return nil, nil
+}
+
+func TestExecutorAbandoned(t *testing.T) {
+	exec := New(WithWorkers(1))
+	ctx := context.Background()
+	task := stubbornTask{make(chan struct{})}
+	assert.NoError(t, exec.Execute(ctx, 1, task, WithTimeout(10*time.Millisecond)))
+	result := <-exec.Results(ctx)
+	assert.Equal(t, FailureCode(result.Error), FailureCodeTimeout)
+
+	// Задача, брошенная по истечении срока, учитывается, пока не завершится.
+	_, running := exec.Load()
+	assert.Equal(t, running, 1)
+	close(task.release)
+	assert.Eventually(t, func() bool {
+		_, running := exec.Load()
+		return running == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReportProgress(t *testing.T) {
+	// Без получателя сообщение игнорируется.
+	ReportProgress(context.Background(), Progress{Percent: 10})
+
+	var got Progress
+	ctx := WithReporter(context.Background(), func(p Progress) { got = p })
+	ReportProgress(ctx, Progress{Percent: 150, Step: "2/3"})
+	assert.Equal(t, got.Percent, 100.0)
+	assert.Equal(t, got.Step, "2/3")
+}
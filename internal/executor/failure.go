@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Машиночитаемые коды ошибок выполнения задач.
+const (
+	FailureCodeFailed  = "execution_failed"
+	FailureCodePanic   = "panic"
+	FailureCodeTimeout = "timeout"
+)
+
+// Ошибка выполнения задачи с машиночитаемым кодом.
+// Задачи могут возвращать ее из Execute, чтобы уточнить причину сбоя.
+type Failure struct {
+	code string
+	err  error
+}
+
+func (f *Failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *Failure) Unwrap() error {
+	return f.err
+}
+
+func (f *Failure) Code() string {
+	return f.code
+}
+
+func NewFailure(code string, err error) *Failure {
+	return &Failure{code, err}
+}
+
+// Код ошибки выполнения. Ошибки без кода считаются FailureCodeFailed.
+func FailureCode(err error) string {
+	var f *Failure
+	if errors.As(err, &f) {
+		return f.code
+	}
+	return FailureCodeFailed
+}
+
+func panicFailure(v any) *Failure {
+	return NewFailure(FailureCodePanic, fmt.Errorf("паника при выполнении задачи: %v", v))
+}
+
+func timeoutFailure() *Failure {
+	return NewFailure(FailureCodeTimeout, errors.New("превышено время выполнения задачи"))
+}
@@ -2,9 +2,13 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"task-api/pkg/syncmap"
+	"runtime"
+	"slices"
+	"sync"
 	"task-api/pkg/timing"
+	"time"
 )
 
 type TaskResult struct {
@@ -14,13 +18,131 @@ type TaskResult struct {
 	Data      any
 }
 
-func New() *executor {
-	return &executor{results: make(chan TaskResult)}
+type Option func(e *executor)
+
+// Число воркеров по умолчанию.
+var defaultWorkers = runtime.NumCPU()
+
+// Время ожидания в очереди, за которое приоритет задачи растет на единицу.
+const defaultAging = 10 * time.Second
+
+// Ограничивает число одновременно выполняемых задач. Задача, которая не реагирует
+// на отмену контекста, после истечения срока или отмены продолжает работать в фоне,
+// уже не занимая воркер и квоты, поэтому фактически выполняемых задач может быть
+// больше n. Такие задачи учитываются в Load.
+func WithWorkers(n int) Option {
+	return func(e *executor) {
+		e.workers = n
+	}
+}
+
+// Ограничивает число задач, ожидающих в очереди. 0 - без ограничения.
+func WithQueueSize(n int) Option {
+	return func(e *executor) {
+		e.queueSize = n
+	}
+}
+
+// Время ожидания, за которое приоритет задачи в очереди растет на единицу,
+// чтобы задачи с низким приоритетом не ждали бесконечно. 0 - без старения.
+func WithAging(d time.Duration) Option {
+	return func(e *executor) {
+		e.aging = d
+	}
+}
+
+func New(opts ...Option) *executor {
+	e := &executor{
+		workers: defaultWorkers,
+		aging:   defaultAging,
+		results: make(chan TaskResult),
+		running: make(map[uint64]*job),
+		limits:  make(map[QuotaKey]int),
+		usage:   make(map[QuotaKey]int),
+		sending: make(map[uint64]time.Time),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.workers <= 0 {
+		e.workers = 1
+	}
+	e.cond = sync.NewCond(&e.mu)
+	e.wg.Add(e.workers)
+	for range e.workers {
+		go e.work()
+	}
+	return e
+}
+
+type ExecuteOption func(j *job)
+
+// Ограничивает время одной попытки выполнения, отсчет с момента запуска.
+// По истечении срока попытка завершается ошибкой, а задача, не реагирующая
+// на контекст, дорабатывает в фоне (см. WithWorkers).
+func WithTimeout(d time.Duration) ExecuteOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+// Крайний срок выполнения задачи.
+func WithDeadline(t time.Time) ExecuteOption {
+	return func(j *job) {
+		j.deadline = t
+	}
+}
+
+// Приоритет задачи: задачи с большим приоритетом выполняются раньше.
+func WithPriority(p int) ExecuteOption {
+	return func(j *job) {
+		j.priority = p
+	}
+}
+
+type job struct {
+	taskID   uint64
+	task     Task
+	ctx      context.Context
+	cancel   context.CancelFunc
+	canceled bool
+	timeout  time.Duration
+	deadline time.Time
+	priority int
+	queuedAt time.Time
+	quotas   []QuotaKey
+}
+
+// Срок, до которого должна завершиться задача, запущенная в момент start.
+func (j *job) deadlineFrom(start time.Time) (time.Time, bool) {
+	deadline := j.deadline
+	if j.timeout > 0 {
+		if d := start.Add(j.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	return deadline, !deadline.IsZero()
 }
 
 type executor struct {
-	results chan TaskResult
-	aborts  syncmap.Map[uint64, chan struct{}]
+	workers   int
+	queueSize int
+	aging     time.Duration
+	results   chan TaskResult
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*job
+	running map[uint64]*job
+	limits  map[QuotaKey]int
+	// Число выполняемых задач по квотам.
+	usage map[QuotaKey]int
+	// Время, с которого результаты задач ждут получателя.
+	sending map[uint64]time.Time
+	// Задачи, брошенные после истечения срока или отмены, но еще не завершившиеся.
+	abandoned int
+	stopped   bool
+	wg        sync.WaitGroup
 }
 
 // Results implements TaskExecutor.
@@ -31,45 +153,256 @@ func (e *executor) Results(context.Context) <-chan TaskResult {
 var _ Executor = (*executor)(nil)
 
 // Execute implements TaskExecutor.
-func (e *executor) Execute(ctx context.Context, taskID uint64, task Task) error {
-	abort := make(chan struct{})
-	e.aborts.Set(taskID, abort)
-	ctx, cancel := context.WithCancel(ctx)
-	go func() {
-		select {
-		case ev := <-e.execute(ctx, taskID, task):
-			e.aborts.Delete(taskID)
-			e.results <- ev
-		case <-abort:
-			e.aborts.Delete(taskID)
-			cancel()
-		}
-	}()
+func (e *executor) Execute(ctx context.Context, taskID uint64, task Task, opts ...ExecuteOption) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return NewError(ErrCodeStopped, "исполнитель остановлен и не принимает задачи")
+	}
+	if _, ok := e.running[taskID]; ok || e.position(taskID) >= 0 {
+		msg := fmt.Sprintf("задача с id %d уже передана на исполнение", taskID)
+		return NewError(ErrCodeBadInput, msg)
+	}
+	if e.queueSize > 0 && len(e.queue) >= e.queueSize {
+		msg := fmt.Sprintf("очередь задач заполнена (%d)", e.queueSize)
+		return NewError(ErrCodeQueueFull, msg)
+	}
+	// Задача живет дольше запроса, который ее создал.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j := &job{
+		taskID:   taskID,
+		task:     task,
+		ctx:      ctx,
+		cancel:   cancel,
+		queuedAt: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	e.queue = append(e.queue, j)
+	e.cond.Signal()
 	return nil
 }
 
 // Cancel implements TaskExecutor.
 func (e *executor) Cancel(ctx context.Context, taskID uint64) error {
-	abort, ok := e.aborts.Get(taskID)
-	if !ok {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if i := e.position(taskID); i >= 0 {
+		e.queue[i].cancel()
+		e.queue = slices.Delete(e.queue, i, i+1)
+		return nil
+	}
+	j, ok := e.running[taskID]
+	if !ok || j.canceled {
 		msg := fmt.Sprintf("задачи с id %d нет среди выполняемых", taskID)
 		return NewError(ErrCodeBadInput, msg)
 	}
-	close(abort)
+	j.canceled = true
+	j.cancel()
 	return nil
 }
 
-func (e *executor) execute(ctx context.Context, taskID uint64, task Task) chan TaskResult {
-	result := make(chan TaskResult)
+// QueuePosition implements TaskExecutor.
+func (e *executor) QueuePosition(ctx context.Context, taskID uint64) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.position(taskID)
+	if i < 0 {
+		return 0, false
+	}
+	// Позиция - число задач, которые будут взяты из очереди раньше.
+	now := time.Now()
+	p := e.effectivePriority(e.queue[i], now)
+	pos := 1
+	for k, j := range e.queue {
+		other := e.effectivePriority(j, now)
+		if other > p || other == p && k < i {
+			pos++
+		}
+	}
+	return pos, true
+}
+
+// Shutdown implements Executor.
+func (e *executor) Shutdown(ctx context.Context) {
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return
+	}
+	e.stopped = true
+	for _, j := range e.queue {
+		j.cancel()
+	}
+	e.queue = nil
+	e.mu.Unlock()
+	e.cond.Broadcast()
+
+	done := make(chan struct{})
 	go func() {
-		data, err := task.Execute(ctx)
-		result <- TaskResult{
-			TaskID:    taskID,
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		e.mu.Lock()
+		for _, j := range e.running {
+			j.canceled = true
+			j.cancel()
+		}
+		e.mu.Unlock()
+		<-done
+	}
+	close(e.results)
+}
+
+// ResultsBacklog implements Executor.
+func (e *executor) ResultsBacklog(ctx context.Context) (int, time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var oldest time.Duration
+	for _, since := range e.sending {
+		oldest = max(oldest, time.Since(since))
+	}
+	return len(e.sending), oldest
+}
+
+// Число задач в очереди и выполняемых задач, включая брошенные после истечения
+// срока или отмены, но еще не завершившиеся.
+func (e *executor) Load() (queued, running int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.queue), len(e.running) + e.abandoned
+}
+
+func (e *executor) position(taskID uint64) int {
+	return slices.IndexFunc(e.queue, func(j *job) bool { return j.taskID == taskID })
+}
+
+// Приоритет задачи с учетом времени ожидания в очереди.
+func (e *executor) effectivePriority(j *job, now time.Time) int {
+	if e.aging <= 0 {
+		return j.priority
+	}
+	return j.priority + int(now.Sub(j.queuedAt)/e.aging)
+}
+
+// Индекс задачи с наибольшим приоритетом, среди равных - самой ранней,
+// из задач, не превышающих квоты. -1, если таких задач нет.
+func (e *executor) next() int {
+	now := time.Now()
+	best, bestPriority := -1, 0
+	for i, j := range e.queue {
+		if !e.withinQuota(j) {
+			continue
+		}
+		if p := e.effectivePriority(j, now); best < 0 || p > bestPriority {
+			best, bestPriority = i, p
+		}
+	}
+	return best
+}
+
+// Цикл воркера: берет задачу из очереди, выполняет и отдает результат.
+// Результат отмененной задачи отбрасывается. Воркер завершается после остановки исполнителя.
+func (e *executor) work() {
+	defer e.wg.Done()
+	for {
+		e.mu.Lock()
+		i := e.next()
+		for i < 0 {
+			if e.stopped {
+				e.mu.Unlock()
+				return
+			}
+			e.cond.Wait()
+			i = e.next()
+		}
+		j := e.queue[i]
+		e.queue = slices.Delete(e.queue, i, i+1)
+		e.running[j.taskID] = j
+		e.acquire(j)
+		e.mu.Unlock()
+
+		data, err := e.execute(j)
+
+		e.mu.Lock()
+		delete(e.running, j.taskID)
+		e.release(j)
+		canceled := j.canceled
+		if !canceled {
+			e.sending[j.taskID] = time.Now()
+		}
+		e.mu.Unlock()
+		// Освободившиеся квоты могут разрешить запуск задач, ожидающих в очереди.
+		e.cond.Broadcast()
+		j.cancel()
+		if canceled {
+			continue
+		}
+		e.results <- TaskResult{
+			TaskID:    j.taskID,
 			Timestamp: timing.Timestamp(),
 			Error:     err,
 			Data:      data,
 		}
-		close(result)
+		e.mu.Lock()
+		delete(e.sending, j.taskID)
+		e.mu.Unlock()
+	}
+}
+
+type outcome struct {
+	data any
+	err  error
+}
+
+// Учитывает задачу, которую воркер бросил, пока она не завершится.
+func (e *executor) abandon(done <-chan outcome) {
+	e.mu.Lock()
+	e.abandoned++
+	e.mu.Unlock()
+	go func() {
+		<-done
+		e.mu.Lock()
+		e.abandoned--
+		e.mu.Unlock()
 	}()
-	return result
+}
+
+// Выполняет задачу с учетом срока. Задача, не уложившаяся в срок,
+// завершается ошибкой FailureCodeTimeout, даже если не реагирует на контекст.
+func (e *executor) execute(j *job) (any, error) {
+	ctx := j.ctx
+	if deadline, ok := j.deadlineFrom(time.Now()); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var out outcome
+		defer func() {
+			if v := recover(); v != nil {
+				out = outcome{err: panicFailure(v)}
+			}
+			done <- out
+		}()
+		out.data, out.err = j.task.Execute(ctx)
+	}()
+	select {
+	case out := <-done:
+		if out.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, timeoutFailure()
+		}
+		return out.data, out.err
+	case <-ctx.Done():
+		e.abandon(done)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, timeoutFailure()
+		}
+		return nil, ctx.Err()
+	}
 }
@@ -1,6 +1,9 @@
 package executor
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Task interface {
 	Execute(context.Context) (any, error)
@@ -8,7 +11,17 @@ type Task interface {
 
 // Берет задачи на исполнение, исполняет, отменяет и возвращает результаты.
 type Executor interface {
-	Execute(ctx context.Context, taskID uint64, task Task) error
+	Execute(ctx context.Context, taskID uint64, task Task, opts ...ExecuteOption) error
 	Cancel(ctx context.Context, taskID uint64) error
 	Results(ctx context.Context) <-chan TaskResult
+	// Позиция задачи в очереди ожидания, начиная с 1.
+	QueuePosition(ctx context.Context, taskID uint64) (int, bool)
+	// Квоты одновременно выполняемых задач и их использование
+	// задачами пространства имен namespace.
+	Quotas(ctx context.Context, namespace string) []Quota
+	// Число результатов, которые ждут получателя из Results, и время ожидания самого старого.
+	ResultsBacklog(ctx context.Context) (int, time.Duration)
+	// Перестает принимать задачи и убирает задачи из очереди, ждет выполняемые задачи
+	// до отмены ctx, затем отменяет оставшиеся без результата и закрывает Results.
+	Shutdown(ctx context.Context)
 }
@@ -0,0 +1,33 @@
+package executor
+
+import "context"
+
+// Прогресс выполнения задачи.
+type Progress struct {
+	// Процент выполнения, от 0 до 100.
+	Percent float64
+	// Текущий шаг.
+	Step string
+	// Произвольное сообщение.
+	Message string
+}
+
+// Получатель сообщений о прогрессе выполнения задачи.
+type Reporter func(p Progress)
+
+type reporterKey struct{}
+
+// Возвращает контекст, через который задача сообщает о прогрессе получателю r.
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Сообщает о прогрессе выполнения задачи. Без получателя в контексте ничего не делает.
+func ReportProgress(ctx context.Context, p Progress) {
+	r, ok := ctx.Value(reporterKey{}).(Reporter)
+	if !ok {
+		return
+	}
+	p.Percent = min(max(p.Percent, 0), 100)
+	r(p)
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// Область квоты одновременно выполняемых задач.
+type QuotaScope string
+
+const (
+	QuotaScopeTaskType QuotaScope = "task_type"
+	QuotaScopeOwner    QuotaScope = "owner"
+)
+
+// Квота, на которую расходуется задача, например тип задачи или владелец ключа API.
+type QuotaKey struct {
+	Scope QuotaScope
+	Name  string
+	// Пространство имен задачи. Ограничение, заданное без пространства имен,
+	// действует в каждом пространстве отдельно.
+	Namespace string
+}
+
+// Ограничение квоты и ее текущее использование.
+type Quota struct {
+	QuotaKey
+	Limit int
+	// Выполняемые задачи квоты.
+	Running int
+	// Задачи квоты в очереди, в том числе ожидающие освобождения квоты.
+	Queued int
+}
+
+// Ограничивает число одновременно выполняемых задач квоты key. Задачи сверх
+// ограничения остаются в очереди, пока квота не освободится.
+func WithQuota(key QuotaKey, limit int) Option {
+	return func(e *executor) {
+		e.limits[key] = limit
+	}
+}
+
+// Квоты, на которые расходуется задача.
+func WithQuotaKeys(keys ...QuotaKey) ExecuteOption {
+	return func(j *job) {
+		j.quotas = append(j.quotas, keys...)
+	}
+}
+
+// Quotas implements Executor.
+func (e *executor) Quotas(ctx context.Context, namespace string) []Quota {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	quotas := make([]Quota, 0, len(e.limits))
+	for key, limit := range e.limits {
+		if key.Scope == QuotaScopeTaskType && key.Namespace == "" {
+			key.Namespace = namespace
+		}
+		q := Quota{QuotaKey: key, Limit: limit, Running: e.usage[key]}
+		for _, j := range e.queue {
+			if slices.Contains(j.quotas, key) {
+				q.Queued++
+			}
+		}
+		quotas = append(quotas, q)
+	}
+	slices.SortFunc(quotas, func(a, b Quota) int {
+		return cmp.Or(cmp.Compare(a.Scope, b.Scope), cmp.Compare(a.Name, b.Name))
+	})
+	return quotas
+}
+
+// Можно ли запустить задачу, не превысив ее квоты.
+func (e *executor) withinQuota(j *job) bool {
+	for _, key := range j.quotas {
+		if limit, ok := e.limit(key); ok && e.usage[key] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// Ограничение квоты: заданное для ее пространства имен или для всех пространств.
+func (e *executor) limit(key QuotaKey) (int, bool) {
+	if limit, ok := e.limits[key]; ok {
+		return limit, true
+	}
+	key.Namespace = ""
+	limit, ok := e.limits[key]
+	return limit, ok
+}
+
+func (e *executor) acquire(j *job) {
+	for _, key := range j.quotas {
+		e.usage[key]++
+	}
+}
+
+func (e *executor) release(j *job) {
+	for _, key := range j.quotas {
+		if e.usage[key]--; e.usage[key] == 0 {
+			delete(e.usage, key)
+		}
+	}
+}
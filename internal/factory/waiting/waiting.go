@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"task-api/internal/executor"
 	"task-api/internal/operator"
 	"task-api/pkg/fromjson"
 	"time"
@@ -42,15 +43,29 @@ func New(opts map[string]any) (operator.Task, error) {
 }
 
 // Execute implements operator.Task.
+// Раз в секунду сообщает о прогрессе: сколько секунд прошло из durationSec.
 func (w *waitingTask) Execute(ctx context.Context) (any, error) {
-	dur := time.Duration(w.durationSec) * time.Second
-	select {
-	case <-time.After(dur):
-		msg := fmt.Sprintf("задача говорит \"привет\" спустя %d секунд", w.durationSec)
-		return msg, nil
-	case <-ctx.Done():
-		return nil, fmt.Errorf("задача отменена")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for elapsed := 0; elapsed < w.durationSec; {
+		select {
+		case <-ticker.C:
+			elapsed++
+			w.report(ctx, elapsed)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("задача отменена")
+		}
 	}
+	msg := fmt.Sprintf("задача говорит \"привет\" спустя %d секунд", w.durationSec)
+	return msg, nil
+}
+
+func (w *waitingTask) report(ctx context.Context, elapsed int) {
+	executor.ReportProgress(ctx, executor.Progress{
+		Percent: float64(elapsed) * 100 / float64(w.durationSec),
+		Step:    fmt.Sprintf("%d/%d", elapsed, w.durationSec),
+		Message: fmt.Sprintf("прошло %d из %d секунд", elapsed, w.durationSec),
+	})
 }
 
 // Type implements operator.Task.
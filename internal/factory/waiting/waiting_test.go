@@ -2,6 +2,7 @@ package waiting
 
 import (
 	"context"
+	"task-api/internal/executor"
 	"testing"
 	"time"
 
@@ -47,3 +48,19 @@ func TestWaitingExecute(t *testing.T) {
 	assert.Nil(t, res)
 	assert.Error(t, err)
 }
+
+func TestWaitingProgress(t *testing.T) {
+	w, _ := New(map[string]any{
+		"duration_sec": 2,
+	})
+	var reports []executor.Progress
+	ctx := executor.WithReporter(context.Background(), func(p executor.Progress) {
+		reports = append(reports, p)
+	})
+	_, err := w.Execute(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, reports, 2)
+	assert.Equal(t, reports[0].Percent, 50.0)
+	assert.Equal(t, reports[0].Step, "1/2")
+	assert.Equal(t, reports[1].Percent, 100.0)
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"task-api/api"
+	"task-api/internal/repository"
+	"task-api/pkg/webservice"
+)
+
+type admin struct {
+	repo repository.Repository
+}
+
+func NewAdmin(r repository.Repository) Admin {
+	return &admin{r}
+}
+
+func (g *admin) ListTasks(ctx context.Context, req *api.AdminListTasksRequest, res *api.ListTasksResponse) error {
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	q, err := listQuery(ctx, &req.ListTasksRequest)
+	if err != nil {
+		return err
+	}
+	q.Namespace = req.Namespace
+	return listTasks(repository.WithAllNamespaces(ctx), g.repo, q, res)
+}
+
+func (g *admin) ListNamespaces(ctx context.Context, req *api.ListNamespacesRequest, res *api.ListNamespacesResponse) error {
+	if err := requireAdmin(ctx); err != nil {
+		return err
+	}
+	list, err := g.repo.List(repository.WithAllNamespaces(ctx), repository.ListQuery{})
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int)
+	res.Namespaces = []api.NamespaceSummary{}
+	for _, task := range list.Tasks {
+		ns := task.NamespaceOrDefault()
+		i, ok := index[ns]
+		if !ok {
+			i = len(res.Namespaces)
+			index[ns] = i
+			res.Namespaces = append(res.Namespaces, api.NamespaceSummary{Namespace: ns})
+		}
+		res.Namespaces[i].TaskCount++
+		if !task.Status.Terminal() {
+			res.Namespaces[i].ActiveCount++
+		}
+	}
+	slices.SortFunc(res.Namespaces, func(a, b api.NamespaceSummary) int {
+		return strings.Compare(a.Namespace, b.Namespace)
+	})
+	return nil
+}
+
+// Проверяет, что запрос выполняется администраторским ключом API.
+// Без аутентификации апи администратора доступно всем.
+func requireAdmin(ctx context.Context) error {
+	if p, ok := webservice.PrincipalFromContext(ctx); ok && !p.Admin {
+		msg := fmt.Sprintf("ключ API `%s` не администраторский", p.Name)
+		return NewError(ErrCodeForbidden, msg)
+	}
+	return nil
+}
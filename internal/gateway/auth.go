@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"task-api/internal/repository"
+	"task-api/pkg/webservice"
+)
+
+// Владелец ключа API запроса. Пусто, если запрос выполняется без ключа.
+func principalName(ctx context.Context) string {
+	if p, ok := webservice.PrincipalFromContext(ctx); ok {
+		return p.Name
+	}
+	return ""
+}
+
+// Владелец, задачами которого ограничен запрос. Пусто - задачи всех владельцев.
+func ownerFilter(ctx context.Context) string {
+	if p, ok := webservice.PrincipalFromContext(ctx); ok && p.OwnTasksOnly {
+		return p.Name
+	}
+	return ""
+}
+
+// Проверяет, что ключу API запроса разрешен тип задачи.
+func authorizeTaskType(ctx context.Context, taskType string) error {
+	p, ok := webservice.PrincipalFromContext(ctx)
+	if ok && !p.AllowsTaskType(taskType) {
+		msg := fmt.Sprintf("ключу API `%s` не разрешен тип задачи `%s`", p.Name, taskType)
+		return NewError(ErrCodeForbidden, msg)
+	}
+	return nil
+}
+
+// Проверяет, что задача видна ключу API запроса. Чужие задачи для ключа
+// с доступом только к своим задачам не существуют.
+func authorizeTask(ctx context.Context, task repository.Task) error {
+	if owner := ownerFilter(ctx); owner != "" && task.Owner != owner {
+		return NewError(ErrCodeNotFound, fmt.Sprintf("задача с id %d не найдена", task.ID))
+	}
+	return nil
+}
+
+// Находит задачу и проверяет, что она видна ключу API запроса.
+func (g *gateway) findTask(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	task, err := g.repo.Find(ctx, taskID)
+	if err != nil {
+		if repoErr, ok := err.(*repository.Error); ok {
+			if repoErr.Code() == repository.ErrCodeNotFound {
+				return nil, NewError(ErrCodeNotFound, repoErr.Error())
+			}
+		}
+		return nil, err
+	}
+	if err := authorizeTask(ctx, *task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Проверяет, что задача видна ключу API, ограниченному своими задачами.
+func (g *gateway) authorizeTaskID(ctx context.Context, taskID uint64) error {
+	if ownerFilter(ctx) == "" {
+		return nil
+	}
+	_, err := g.findTask(ctx, taskID)
+	return err
+}
@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"task-api/api"
+	"task-api/internal/operator"
+	"task-api/internal/repository"
+	"task-api/pkg/labels"
+)
+
+func (g *gateway) CreateTasks(ctx context.Context, req *api.CreateTasksRequest, res *api.CreateTasksResponse) error {
+	if req.AllOrNothing {
+		return g.createTasksAtomic(ctx, req, res)
+	}
+	res.Results = make([]api.BatchResult, 0, len(req.Tasks))
+	for i := range req.Tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := api.BatchResult{Index: i}
+		var created api.CreateTaskResponse
+		err := req.Tasks[i].Validate()
+		if err != nil {
+			err = NewError(ErrCodeBadInput, err.Error())
+		} else {
+			err = g.CreateTask(ctx, &req.Tasks[i], &created)
+		}
+		if err != nil {
+			result.Error = batchError(err)
+		} else {
+			result.TaskID = created.TaskID
+			result.Status = created.Status
+		}
+		res.Results = append(res.Results, result)
+	}
+	return nil
+}
+
+// Создает задачи одним процессом без связей: при ошибке не создается ни одна.
+func (g *gateway) createTasksAtomic(ctx context.Context, req *api.CreateTasksRequest, res *api.CreateTasksResponse) error {
+	nodes := make([]operator.WorkflowNode, len(req.Tasks))
+	for i := range req.Tasks {
+		task := &req.Tasks[i]
+		if err := task.Validate(); err != nil {
+			return NewError(ErrCodeBadInput, fmt.Sprintf("задача %d: %s", i, err))
+		}
+		if task.IdempotencyKey != "" {
+			msg := fmt.Sprintf("задача %d: ключ идемпотентности не поддерживается с `all_or_nothing`", i)
+			return NewError(ErrCodeBadInput, msg)
+		}
+		optask, err := constructTask(ctx, g.factory, task)
+		if err != nil {
+			return batchItemError(i, err)
+		}
+		params, err := createParams(ctx, task)
+		if err != nil {
+			return batchItemError(i, err)
+		}
+		nodes[i] = operator.WorkflowNode{Task: optask, Params: params}
+	}
+	tasks, err := g.operator.CreateWorkflow(ctx, nodes)
+	if err != nil {
+		return createError(err)
+	}
+	res.Results = make([]api.BatchResult, 0, len(tasks))
+	for i, task := range tasks {
+		res.Results = append(res.Results, api.BatchResult{
+			Index:  i,
+			TaskID: int(task.ID),
+			Status: taskApiStatus(*task),
+		})
+	}
+	return nil
+}
+
+func (g *gateway) CancelTasks(ctx context.Context, req *api.CancelTasksRequest, res *api.CancelTasksResponse) error {
+	ids := req.TaskIDs
+	if req.LabelSelector != "" {
+		var err error
+		ids, err = g.selectTasks(ctx, req.LabelSelector, cancelableStatuses)
+		if err != nil {
+			return err
+		}
+	}
+	res.Results = make([]api.BatchResult, 0, len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := api.BatchResult{Index: i, TaskID: int(id)}
+		var canceled api.CancelTaskResponse
+		if err := g.CancelTask(ctx, &api.CancelTaskRequest{TaskID: id}, &canceled); err != nil {
+			result.Error = batchError(err)
+		} else {
+			result.Status = canceled.Status
+		}
+		res.Results = append(res.Results, result)
+	}
+	return nil
+}
+
+func (g *gateway) DeleteTasks(ctx context.Context, req *api.DeleteTasksRequest, res *api.DeleteTasksResponse) error {
+	ids := req.TaskIDs
+	if req.LabelSelector != "" {
+		var err error
+		ids, err = g.selectTasks(ctx, req.LabelSelector, nil)
+		if err != nil {
+			return err
+		}
+	}
+	res.Results = make([]api.BatchResult, 0, len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := api.BatchResult{Index: i, TaskID: int(id)}
+		var deleted api.DeleteTaskResponse
+		if err := g.DeleteTask(ctx, &api.DeleteTaskRequest{TaskID: id}, &deleted); err != nil {
+			result.Error = batchError(err)
+		}
+		res.Results = append(res.Results, result)
+	}
+	return nil
+}
+
+// Статусы задач, которые можно отменить.
+var cancelableStatuses = []repository.Status{
+	repository.StatusQueued,
+	repository.StatusRunning,
+	repository.StatusRetrying,
+	repository.StatusScheduled,
+	repository.StatusBlocked,
+}
+
+// Id задач с заданными статусами, подходящих под селектор меток.
+// Если задач больше api.MaxBatchSize, запрос отклоняется целиком,
+// а не применяется к части задач.
+func (g *gateway) selectTasks(ctx context.Context, selector string, statuses []repository.Status) ([]uint64, error) {
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return nil, NewError(ErrCodeBadInput, err.Error())
+	}
+	list, err := g.repo.List(ctx, repository.ListQuery{
+		Statuses: statuses,
+		Selector: s,
+		Owner:    ownerFilter(ctx),
+		Limit:    api.MaxBatchSize + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Tasks) > api.MaxBatchSize {
+		msg := fmt.Sprintf("под селектор `%s` подходит больше %d задач, уточните селектор", selector, api.MaxBatchSize)
+		return nil, NewError(ErrCodeBadInput, msg)
+	}
+	ids := make([]uint64, 0, len(list.Tasks))
+	for _, task := range list.Tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids, nil
+}
+
+func batchError(err error) *api.BatchError {
+	if gatErr, ok := err.(*Error); ok {
+		return &api.BatchError{Code: gatErr.Code().String(), Message: gatErr.Error()}
+	}
+	return &api.BatchError{Code: "internal", Message: "что-то пошло не так"}
+}
+
+// Ошибка задачи с номером i, отклоняющая весь пакет.
+func batchItemError(i int, err error) error {
+	if gatErr, ok := err.(*Error); ok {
+		return NewError(gatErr.Code(), fmt.Sprintf("задача %d: %s", i, gatErr.Error()))
+	}
+	return err
+}
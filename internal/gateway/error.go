@@ -5,8 +5,28 @@ type ErrCode int
 const (
 	ErrCodeBadInput ErrCode = iota
 	ErrCodeNotFound
+	ErrCodeUnavailable
+	ErrCodeConflict
+	// Ключу API не разрешено действие.
+	ErrCodeForbidden
 )
 
+func (c ErrCode) String() string {
+	switch c {
+	case ErrCodeBadInput:
+		return "bad_input"
+	case ErrCodeNotFound:
+		return "not_found"
+	case ErrCodeUnavailable:
+		return "unavailable"
+	case ErrCodeConflict:
+		return "conflict"
+	case ErrCodeForbidden:
+		return "forbidden"
+	}
+	return "internal"
+}
+
 type Error struct {
 	code ErrCode
 	msg  string
@@ -3,9 +3,14 @@ package gateway
 import (
 	"context"
 	"task-api/api"
+	"task-api/internal/executor"
 	"task-api/internal/operator"
 	"task-api/internal/repository"
+	"task-api/internal/scheduler"
+	"task-api/pkg/labels"
+	"task-api/pkg/webservice"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -43,11 +48,42 @@ func TestGatewayListTasks(t *testing.T) {
 	assert.Equal(t, res.Tasks[1].Status, api.TaskStatusExecuted)
 	assert.Equal(t, res.Tasks[1].TaskID, 43)
 	assert.Equal(t, res.Tasks[1].TaskType, "test43")
-	assert.Equal(t, res.Tasks[2].Status, api.TaskStatusCreated)
+	assert.Equal(t, res.Tasks[2].Status, api.TaskStatusQueued)
 	assert.Equal(t, res.Tasks[2].TaskID, 44)
 	assert.Equal(t, res.Tasks[2].TaskType, "test44")
 }
 
+func TestGatewayListTasksQuery(t *testing.T) {
+	repo, oper, fact := setupDeps()
+	gat := New(repo, oper, fact)
+	ctx := context.Background()
+
+	var res api.ListTasksResponse
+	err := gat.ListTasks(ctx, &api.ListTasksRequest{
+		Status:        []api.TaskStatus{api.TaskStatusQueued},
+		TaskType:      []string{"waiting"},
+		CreatedFrom:   "2024-01-01T00:00:00Z",
+		Labels:        map[string]string{"env": "prod"},
+		LabelSelector: "team!=ml",
+		SortBy:        api.TaskSortPriority,
+		Cursor:        "cursor",
+	}, &res)
+	assert.Nil(t, err)
+	assert.Equal(t, res.NextCursor, "next")
+	assert.Equal(t, res.Tasks[2].Priority, 5)
+	assert.Equal(t, repo.query.Statuses, []repository.Status{repository.StatusQueued})
+	assert.Equal(t, repo.query.Types, []string{"waiting"})
+	assert.Equal(t, repo.query.CreatedFrom, int64(1704067200))
+	assert.Equal(t, repo.query.Selector, labels.Selector{
+		{Key: "env", Op: labels.Equals, Values: []string{"prod"}},
+		{Key: "team", Op: labels.NotEquals, Values: []string{"ml"}},
+	})
+	assert.Equal(t, repo.query.SortBy, repository.SortByPriority)
+	assert.True(t, repo.query.Desc)
+	assert.Equal(t, repo.query.Limit, api.DefaultListLimit)
+	assert.Equal(t, repo.query.Cursor, "cursor")
+}
+
 func TestGatewayGetTaskResult(t *testing.T) {
 	repo, oper, fact := setupDeps()
 	gat := New(repo, oper, fact)
@@ -69,6 +105,15 @@ func TestGatewayGetTaskResult(t *testing.T) {
 	assert.NotEmpty(t, res.Error)
 	assert.Equal(t, res.Result, nil)
 
+	res = api.GetTaskResultResponse{}
+	err = gat.GetTaskResult(ctx, &api.GetTaskResultRequest{
+		TaskID: 3,
+	}, &res)
+	assert.Nil(t, err)
+	assert.Equal(t, res.Status, api.TaskStatusFailed)
+	assert.Equal(t, res.Error, "test")
+	assert.Equal(t, res.ErrorCode, "execution_failed")
+
 	res = api.GetTaskResultResponse{}
 	err = gat.GetTaskResult(ctx, &api.GetTaskResultRequest{
 		TaskID: 13,
@@ -110,6 +155,16 @@ func TestGatewayGetTaskDetails(t *testing.T) {
 	assert.NotEmpty(t, res.ExecutedAt)
 	assert.Equal(t, res.Status, api.TaskStatusExecuted)
 
+	res = api.GetTaskDetailsResponse{}
+	err = gat.GetTaskDetails(ctx, &api.GetTaskDetailsRequest{
+		TaskID: 3,
+	}, &res)
+	assert.Nil(t, err)
+	assert.Equal(t, res.Status, api.TaskStatusFailed)
+	assert.NotEmpty(t, res.FailedAt)
+	assert.Empty(t, res.ExecutedAt)
+	assert.Equal(t, res.ErrorCode, "execution_failed")
+
 	res = api.GetTaskDetailsResponse{}
 	err = gat.GetTaskDetails(ctx, &api.GetTaskDetailsRequest{
 		TaskID: 13,
@@ -161,8 +216,290 @@ func TestGatewayCancelTask(t *testing.T) {
 	assert.Equal(t, gatErr.code, ErrCodeNotFound)
 }
 
+func TestWorkflowsCreate(t *testing.T) {
+	_, oper, fact := setupDeps()
+	flows := NewWorkflows(oper, fact)
+	ctx := context.Background()
+
+	var res api.CreateWorkflowResponse
+	err := flows.Create(ctx, &api.CreateWorkflowRequest{
+		Tasks: []api.WorkflowTask{
+			{Key: "a", CreateTaskRequest: api.CreateTaskRequest{TaskType: "test"}},
+			{Key: "b", After: []string{"a"}, CreateTaskRequest: api.CreateTaskRequest{
+				TaskType: "test",
+				Priority: 5,
+				Labels:   map[string]string{"env": "prod"},
+			}},
+		},
+	}, &res)
+	assert.Nil(t, err)
+	assert.Len(t, oper.createdNodes, 2)
+	assert.Equal(t, oper.createdNodes[1].DependsOn, []int{0})
+	assert.Len(t, res.Tasks, 2)
+	assert.Equal(t, res.Tasks[1].Key, "b")
+	assert.Equal(t, res.Tasks[1].TaskID, 101)
+	assert.Equal(t, res.Tasks[1].Priority, 5)
+	assert.Equal(t, res.Tasks[1].Labels, map[string]string{"env": "prod"})
+	assert.Equal(t, res.Tasks[1].Namespace, repository.DefaultNamespace)
+
+	err = flows.Create(ctx, &api.CreateWorkflowRequest{
+		Tasks: []api.WorkflowTask{
+			{Key: "a", After: []string{"c"}, CreateTaskRequest: api.CreateTaskRequest{TaskType: "test"}},
+		},
+	}, &res)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+}
+
+func TestGatewayWaitTask(t *testing.T) {
+	repo, oper, fact := setupDeps()
+	gat := New(repo, oper, fact)
+	ctx := context.Background()
+
+	var res api.WaitTaskResponse
+	err := gat.WaitTask(ctx, &api.WaitTaskRequest{TaskID: 1}, &res)
+	assert.Nil(t, err)
+	assert.True(t, res.Completed)
+	assert.Equal(t, res.Status, api.TaskStatusExecuted)
+	assert.Equal(t, res.Result, 42)
+
+	res = api.WaitTaskResponse{}
+	err = gat.WaitTask(ctx, &api.WaitTaskRequest{TaskID: 14, TimeoutSec: 1}, &res)
+	assert.Nil(t, err)
+	assert.False(t, res.Completed)
+	assert.Equal(t, res.Status, api.TaskStatusRunning)
+
+	// Срок запроса истек раньше `timeout_sec`: ошибка вместо незавершенной задачи.
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = gat.WaitTask(short, &api.WaitTaskRequest{TaskID: 14, TimeoutSec: 5}, &res)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = gat.WaitTask(ctx, &api.WaitTaskRequest{TaskID: 13}, &res)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeNotFound)
+}
+
+func TestGatewayBatch(t *testing.T) {
+	repo, oper, fact := setupDeps()
+	gat := New(repo, oper, fact)
+	ctx := context.Background()
+
+	var created api.CreateTasksResponse
+	err := gat.CreateTasks(ctx, &api.CreateTasksRequest{
+		Tasks: []api.CreateTaskRequest{{TaskType: "test"}, {}},
+	}, &created)
+	assert.Nil(t, err)
+	assert.Len(t, created.Results, 2)
+	assert.Equal(t, created.Results[0].TaskID, 42)
+	assert.Nil(t, created.Results[0].Error)
+	assert.Equal(t, created.Results[1].Error.Code, "bad_input")
+
+	err = gat.CreateTasks(ctx, &api.CreateTasksRequest{
+		Tasks:        []api.CreateTaskRequest{{TaskType: "test"}, {}},
+		AllOrNothing: true,
+	}, &created)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+
+	err = gat.CreateTasks(ctx, &api.CreateTasksRequest{
+		Tasks:        []api.CreateTaskRequest{{TaskType: "test"}, {TaskType: "test"}},
+		AllOrNothing: true,
+	}, &created)
+	assert.Nil(t, err)
+	assert.Len(t, oper.createdNodes, 2)
+	assert.Equal(t, created.Results[1].TaskID, 101)
+
+	var canceled api.CancelTasksResponse
+	err = gat.CancelTasks(ctx, &api.CancelTasksRequest{TaskIDs: []uint64{1, 13}}, &canceled)
+	assert.Nil(t, err)
+	assert.Equal(t, canceled.Results[0].Status, api.TaskStatusAborted)
+	assert.Equal(t, canceled.Results[1].Error.Code, "not_found")
+
+	var deleted api.DeleteTasksResponse
+	err = gat.DeleteTasks(ctx, &api.DeleteTasksRequest{TaskIDs: []uint64{2, 13}}, &deleted)
+	assert.Nil(t, err)
+	assert.Nil(t, deleted.Results[0].Error)
+	assert.Equal(t, oper.deletedTaskID, uint64(2))
+	assert.Equal(t, deleted.Results[1].Error.Code, "not_found")
+
+	// Задачи по селектору меток выбирает хранилище: задачи 42, 43 и 44.
+	err = gat.CancelTasks(ctx, &api.CancelTasksRequest{LabelSelector: "env=prod"}, &canceled)
+	assert.Nil(t, err)
+	assert.Len(t, canceled.Results, 3)
+	assert.Equal(t, oper.canceledTaskID, uint64(44))
+	assert.Equal(t, repo.query.Statuses, cancelableStatuses)
+	assert.Equal(t, repo.query.Limit, api.MaxBatchSize+1)
+
+	err = gat.DeleteTasks(ctx, &api.DeleteTasksRequest{LabelSelector: "env in (prod, dev)"}, &deleted)
+	assert.Nil(t, err)
+	assert.Len(t, deleted.Results, 3)
+	assert.Equal(t, oper.deletedTaskID, uint64(44))
+	assert.Empty(t, repo.query.Statuses)
+	assert.Len(t, repo.query.Selector, 1)
+}
+
+func TestGatewayBatchSelectorLimit(t *testing.T) {
+	_, oper, fact := setupDeps()
+	store := repository.New()
+	gat := New(store, oper, fact)
+	ctx := context.Background()
+	for range api.MaxBatchSize + 1 {
+		store.Create(ctx, repository.Task{Type: "test", Status: repository.StatusQueued, Labels: map[string]string{"env": "prod"}})
+	}
+
+	// Часть подходящих задач не обрабатывается молча: запрос отклоняется.
+	var canceled api.CancelTasksResponse
+	err := gat.CancelTasks(ctx, &api.CancelTasksRequest{LabelSelector: "env=prod"}, &canceled)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+	assert.Zero(t, oper.canceledTaskID)
+}
+
+func TestGatewayAuthorization(t *testing.T) {
+	repo, oper, fact := setupDeps()
+	gat := New(repo, oper, fact)
+	ctx := webservice.WithPrincipal(context.Background(), &webservice.Principal{
+		Name:         "ann",
+		TaskTypes:    []string{"test"},
+		OwnTasksOnly: true,
+	})
+
+	var created api.CreateTaskResponse
+	err := gat.CreateTask(ctx, &api.CreateTaskRequest{TaskType: "other"}, &created)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeForbidden)
+	err = gat.CreateTask(ctx, &api.CreateTaskRequest{TaskType: "test"}, &created)
+	assert.Nil(t, err)
+	assert.Equal(t, oper.createdParams.Owner, "ann")
+
+	var list api.ListTasksResponse
+	assert.Nil(t, gat.ListTasks(ctx, &api.ListTasksRequest{}, &list))
+	assert.Equal(t, repo.query.Owner, "ann")
+
+	// Чужая задача для ключа с доступом только к своим задачам не существует.
+	var details api.GetTaskDetailsResponse
+	err = gat.GetTaskDetails(ctx, &api.GetTaskDetailsRequest{TaskID: 1}, &details)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeNotFound)
+	var canceled api.CancelTaskResponse
+	err = gat.CancelTask(ctx, &api.CancelTaskRequest{TaskID: 1}, &canceled)
+	assert.IsType(t, &Error{}, err)
+	assert.Zero(t, oper.canceledTaskID)
+}
+
+func TestSchedulesAuthorization(t *testing.T) {
+	_, oper, fact := setupDeps()
+	store := repository.New()
+	sch := scheduler.New(oper, fact)
+	defer sch.Stop()
+	scheds := NewSchedules(sch, store)
+	ann := webservice.WithPrincipal(context.Background(), &webservice.Principal{Name: "ann", OwnTasksOnly: true})
+	bob := webservice.WithPrincipal(context.Background(), &webservice.Principal{Name: "bob", OwnTasksOnly: true})
+
+	var created api.CreateScheduleResponse
+	req := &api.CreateScheduleRequest{Cron: "* * * * *", TaskType: "test"}
+	assert.Nil(t, scheds.Create(ann, req, &created))
+	assert.Equal(t, created.Schedule.Owner, "ann")
+	id := created.Schedule.ScheduleID
+	store.Create(context.Background(), repository.Task{Type: "test", ScheduleID: uint64(id), Owner: "ann"})
+
+	var runs api.ListScheduleRunsResponse
+	assert.Nil(t, scheds.ListRuns(ann, &api.ListScheduleRunsRequest{ScheduleID: id}, &runs))
+	assert.Len(t, runs.Runs, 1)
+	// Запуски и расписания других владельцев ключу с доступом только к своим задачам не видны.
+	assert.Nil(t, scheds.ListRuns(bob, &api.ListScheduleRunsRequest{ScheduleID: id}, &runs))
+	assert.Empty(t, runs.Runs)
+	var list api.ListSchedulesResponse
+	assert.Nil(t, scheds.List(bob, &api.ListSchedulesRequest{}, &list))
+	assert.Empty(t, list.Schedules)
+
+	var paused api.PauseScheduleResponse
+	err := scheds.Pause(bob, &api.PauseScheduleRequest{ScheduleID: id}, &paused)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeNotFound)
+	var deleted api.DeleteScheduleResponse
+	err = scheds.Delete(bob, &api.DeleteScheduleRequest{ScheduleID: id}, &deleted)
+	assert.IsType(t, &Error{}, err)
+	assert.Nil(t, scheds.Pause(ann, &api.PauseScheduleRequest{ScheduleID: id}, &paused))
+	assert.Equal(t, paused.Schedule.Status, api.ScheduleStatusPaused)
+}
+
+func TestAdmin(t *testing.T) {
+	repo, _, _ := setupDeps()
+	adm := NewAdmin(repo)
+	ctx := webservice.WithPrincipal(context.Background(), &webservice.Principal{Name: "ann", Namespace: "team-a"})
+
+	var list api.ListTasksResponse
+	err := adm.ListTasks(ctx, &api.AdminListTasksRequest{}, &list)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeForbidden)
+
+	ctx = webservice.WithPrincipal(context.Background(), &webservice.Principal{Name: "root", Admin: true})
+	req := &api.AdminListTasksRequest{Namespace: "team-b"}
+	assert.Nil(t, adm.ListTasks(ctx, req, &list))
+	assert.Equal(t, repo.query.Namespace, "team-b")
+	assert.Equal(t, len(list.Tasks), 3)
+	assert.Equal(t, list.Tasks[0].Namespace, repository.DefaultNamespace)
+
+	var namespaces api.ListNamespacesResponse
+	assert.Nil(t, adm.ListNamespaces(ctx, &api.ListNamespacesRequest{}, &namespaces))
+	assert.Equal(t, namespaces.Namespaces, []api.NamespaceSummary{
+		{Namespace: repository.DefaultNamespace, TaskCount: 3, ActiveCount: 1},
+	})
+}
+
+func TestQuotas(t *testing.T) {
+	_, oper, _ := setupDeps()
+	oper.quotas = []executor.Quota{
+		{QuotaKey: executor.QuotaKey{Scope: executor.QuotaScopeOwner, Name: "ann"}, Limit: 20, Running: 3},
+		{QuotaKey: executor.QuotaKey{Scope: executor.QuotaScopeOwner, Name: "bob"}, Limit: 20},
+		{QuotaKey: executor.QuotaKey{Scope: executor.QuotaScopeTaskType, Name: "waiting", Namespace: "default"}, Limit: 5, Running: 5, Queued: 2},
+	}
+	quot := NewQuotas(oper)
+
+	var res api.GetQuotasResponse
+	assert.Nil(t, quot.Get(context.Background(), &api.GetQuotasRequest{}, &res))
+	assert.Equal(t, len(res.Quotas), 3)
+
+	// Квоты чужих ключей не видны.
+	ctx := webservice.WithPrincipal(context.Background(), &webservice.Principal{Name: "ann"})
+	assert.Nil(t, quot.Get(ctx, &api.GetQuotasRequest{}, &res))
+	assert.Equal(t, res.Quotas, []api.Quota{
+		{Scope: "owner", Name: "ann", Limit: 20, Running: 3},
+		{Scope: "task_type", Name: "waiting", Namespace: "default", Limit: 5, Running: 5, Queued: 2},
+	})
+}
+
+func TestGatewayCreateTaskIdempotent(t *testing.T) {
+	repo, oper, fact := setupDeps()
+	gat := New(repo, oper, fact)
+	ctx := context.Background()
+
+	req := &api.CreateTaskRequest{TaskType: "test", IdempotencyKey: "key"}
+	var res api.CreateTaskResponse
+	assert.Nil(t, gat.CreateTask(ctx, req, &res))
+	assert.Equal(t, res.TaskID, 42)
+	assert.Equal(t, repo.keys["key"].TaskID, uint64(42))
+	// Резерв ключа без задачи истекает быстро, ключ с задачей хранится весь срок.
+	assert.LessOrEqual(t, repo.reserved.ExpiresAt, time.Now().Add(idempotencyLease).Unix())
+	assert.Greater(t, repo.keys["key"].ExpiresAt, time.Now().Add(time.Hour).Unix())
+
+	// Повтор не создает новую задачу.
+	oper.createdTask = nil
+	var repeated api.CreateTaskResponse
+	assert.Nil(t, gat.CreateTask(ctx, req, &repeated))
+	assert.Nil(t, oper.createdTask)
+	assert.Equal(t, repeated.TaskID, 42)
+	assert.Equal(t, repeated.Status, api.TaskStatusQueued)
+
+	err := gat.CreateTask(ctx, &api.CreateTaskRequest{TaskType: "test", Priority: 1, IdempotencyKey: "key"}, &res)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeConflict)
+}
+
 func setupDeps() (*mockRepo, *mockOper, *mockFact) {
-	return &mockRepo{}, &mockOper{}, &mockFact{}
+	return &mockRepo{keys: make(map[string]repository.IdempotencyKey)}, &mockOper{}, &mockFact{}
 }
 
 type mockFact struct{}
@@ -173,7 +510,32 @@ func (m *mockFact) Construct(taskType string, opts map[string]any) (operator.Tas
 }
 
 type mockRepo struct {
-	task *repository.Task
+	task     *repository.Task
+	query    repository.ListQuery
+	keys     map[string]repository.IdempotencyKey
+	reserved repository.IdempotencyKey
+}
+
+// ReserveIdempotencyKey implements repository.Repository.
+func (m *mockRepo) ReserveIdempotencyKey(ctx context.Context, key repository.IdempotencyKey) (*repository.IdempotencyKey, error) {
+	if existing, ok := m.keys[key.Key]; ok {
+		return &existing, nil
+	}
+	m.keys[key.Key] = key
+	m.reserved = key
+	return nil, nil
+}
+
+// SaveIdempotencyKey implements repository.Repository.
+func (m *mockRepo) SaveIdempotencyKey(ctx context.Context, key repository.IdempotencyKey) error {
+	m.keys[key.Key] = key
+	return nil
+}
+
+// DeleteIdempotencyKey implements repository.Repository.
+func (m *mockRepo) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	delete(m.keys, key)
+	return nil
 }
 
 // Create implements repository.Repository.
@@ -193,7 +555,7 @@ func (m *mockRepo) Find(ctx context.Context, taskID uint64) (*repository.Task, e
 			ID:         1,
 			CreatedAt:  0,
 			FinishedAt: 60,
-			Aborted:    true,
+			Status:     repository.StatusAborted,
 			Type:       "test42",
 			Options: map[string]any{
 				"test": 42,
@@ -207,34 +569,57 @@ func (m *mockRepo) Find(ctx context.Context, taskID uint64) (*repository.Task, e
 			ID:         1,
 			CreatedAt:  0,
 			FinishedAt: 3600,
+			Status:     repository.StatusExecuted,
 			Error:      "test",
 		}, nil
 	}
+	if taskID == 3 {
+		return &repository.Task{
+			ID:         3,
+			CreatedAt:  0,
+			FinishedAt: 60,
+			Status:     repository.StatusFailed,
+			Error:      "test",
+			ErrorCode:  "execution_failed",
+		}, nil
+	}
 	if taskID == 13 {
 		return nil, repository.NewError(repository.ErrCodeNotFound, "")
 	}
+	if taskID == 42 {
+		return &repository.Task{
+			ID:     42,
+			Type:   "test",
+			Status: repository.StatusQueued,
+		}, nil
+	}
 	return nil, nil
 }
 
 // List implements repository.Repository.
-func (m *mockRepo) List(ctx context.Context) ([]repository.Task, error) {
-	return []repository.Task{
+func (m *mockRepo) List(ctx context.Context, q repository.ListQuery) (*repository.ListResult, error) {
+	m.query = q
+	tasks := []repository.Task{
 		{
 			ID:         42,
 			Type:       "test42",
-			Aborted:    true,
+			Status:     repository.StatusAborted,
 			FinishedAt: 1,
 		},
 		{
 			ID:         43,
 			Type:       "test43",
+			Status:     repository.StatusExecuted,
 			FinishedAt: 1,
 		},
 		{
-			ID:   44,
-			Type: "test44",
+			ID:       44,
+			Type:     "test44",
+			Status:   repository.StatusQueued,
+			Priority: 5,
 		},
-	}, nil
+	}
+	return &repository.ListResult{Tasks: tasks, NextCursor: "next"}, nil
 }
 
 // Update implements repository.Repository.
@@ -249,8 +634,11 @@ func (m *mockRepo) Update(ctx context.Context, taskID uint64, update func(t repo
 
 type mockOper struct {
 	createdTask    operator.Task
+	createdParams  operator.CreateParams
+	createdNodes   []operator.WorkflowNode
 	deletedTaskID  uint64
 	canceledTaskID uint64
+	quotas         []executor.Quota
 }
 
 // Cancel implements operator.Operator.
@@ -263,18 +651,34 @@ func (m *mockOper) Cancel(ctx context.Context, taskID uint64) (*repository.Task,
 		ID:         taskID,
 		CreatedAt:  0,
 		FinishedAt: 90,
-		Aborted:    true,
+		Status:     repository.StatusAborted,
 	}, nil
 }
 
 // Create implements operator.Operator.
-func (m *mockOper) Create(ctx context.Context, task operator.Task) (*repository.Task, error) {
+func (m *mockOper) Create(ctx context.Context, task operator.Task, params operator.CreateParams) (*repository.Task, error) {
 	m.createdTask = task
+	m.createdParams = params
 	return &repository.Task{
 		ID: 42,
 	}, nil
 }
 
+// CreateWorkflow implements operator.Operator.
+func (m *mockOper) CreateWorkflow(ctx context.Context, nodes []operator.WorkflowNode) ([]*repository.Task, error) {
+	m.createdNodes = nodes
+	tasks := make([]*repository.Task, len(nodes))
+	for i := range nodes {
+		tasks[i] = &repository.Task{
+			ID:       uint64(100 + i),
+			Status:   repository.StatusQueued,
+			Priority: nodes[i].Params.Priority,
+			Labels:   nodes[i].Params.Labels,
+		}
+	}
+	return tasks, nil
+}
+
 // Delete implements operator.Operator.
 func (m *mockOper) Delete(ctx context.Context, taskID uint64) error {
 	if taskID == 13 {
@@ -284,6 +688,28 @@ func (m *mockOper) Delete(ctx context.Context, taskID uint64) error {
 	return nil
 }
 
+// Quotas implements operator.Operator.
+func (m *mockOper) Quotas(ctx context.Context) []executor.Quota {
+	return m.quotas
+}
+
+// QueuePosition implements operator.Operator.
+func (m *mockOper) QueuePosition(ctx context.Context, taskID uint64) (int, bool) {
+	return 0, false
+}
+
+// Wait implements operator.Operator.
+func (m *mockOper) Wait(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	switch taskID {
+	case 13:
+		return nil, operator.NewError(operator.ErrCodeNotFound, "")
+	case 14:
+		<-ctx.Done()
+		return &repository.Task{ID: taskID, Status: repository.StatusRunning}, ctx.Err()
+	}
+	return &repository.Task{ID: taskID, Status: repository.StatusExecuted, FinishedAt: 1, Result: 42}, nil
+}
+
 type mockTask struct{}
 
 // Execute implements operator.Task.
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"task-api/api"
+	"task-api/internal/repository"
+	"task-api/pkg/timing"
+	"time"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// Время жизни ключа, по которому задача еще создается. Ключ, оставшийся без задачи
+// после падения сервиса, не блокирует повторы на все время хранения ключей.
+const idempotencyLease = time.Minute
+
+// Создает задачу не больше одного раза на ключ идемпотентности.
+// Повтор с тем же ключом и параметрами возвращает ранее созданную задачу.
+func (g *gateway) createIdempotent(ctx context.Context, req *api.CreateTaskRequest, res *api.CreateTaskResponse) error {
+	key := repository.IdempotencyKey{
+		Key:         scopedKey(ctx, req.IdempotencyKey),
+		Fingerprint: fingerprint(req),
+		ExpiresAt:   time.Now().Add(min(idempotencyLease, g.idempotencyTTL)).Unix(),
+	}
+	existing, err := g.repo.ReserveIdempotencyKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return g.replay(ctx, *existing, req.IdempotencyKey, key.Fingerprint, res)
+	}
+	if err := g.createTask(ctx, req, res); err != nil {
+		// Неудачный запрос можно повторить с тем же ключом.
+		_ = g.repo.DeleteIdempotencyKey(ctx, key.Key)
+		return err
+	}
+	key.TaskID = uint64(res.TaskID)
+	key.ExpiresAt = time.Now().Add(g.idempotencyTTL).Unix()
+	return g.repo.SaveIdempotencyKey(ctx, key)
+}
+
+func (g *gateway) replay(ctx context.Context, key repository.IdempotencyKey, name, fp string, res *api.CreateTaskResponse) error {
+	if key.Fingerprint != fp {
+		msg := fmt.Sprintf("ключ идемпотентности `%s` уже использован с другими параметрами", name)
+		return NewError(ErrCodeConflict, msg)
+	}
+	if key.TaskID == 0 {
+		msg := fmt.Sprintf("запрос с ключом идемпотентности `%s` еще выполняется", name)
+		return NewError(ErrCodeConflict, msg)
+	}
+	task, err := g.repo.Find(ctx, key.TaskID)
+	if err != nil {
+		msg := fmt.Sprintf("задача с id %d, созданная по ключу идемпотентности `%s`, удалена", key.TaskID, name)
+		return NewError(ErrCodeNotFound, msg)
+	}
+	res.TaskID = int(task.ID)
+	res.CreatedAt = timing.Format(task.CreatedAt)
+	res.Options = task.Options
+	res.TaskType = task.Type
+	res.Status = taskApiStatus(*task)
+	return nil
+}
+
+// Ключ идемпотентности в хранилище: ключи разных пространств имен
+// и владельцев ключей API не пересекаются.
+func scopedKey(ctx context.Context, key string) string {
+	if owner := principalName(ctx); owner != "" {
+		key = owner + "/" + key
+	}
+	if ns, ok := repository.NamespaceFromContext(ctx); ok {
+		key = ns + ":" + key
+	}
+	return key
+}
+
+// Отпечаток параметров запроса без самого ключа.
+func fingerprint(req *api.CreateTaskRequest) string {
+	r := *req
+	r.IdempotencyKey = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -7,16 +7,32 @@ import (
 	"task-api/internal/factory"
 	"task-api/internal/operator"
 	"task-api/internal/repository"
+	"task-api/pkg/labels"
 	"task-api/pkg/timing"
+	"time"
 )
 
 type gateway struct {
 	repo     repository.Repository
 	operator operator.Operator
 	factory  factory.Factory
+
+	idempotencyTTL time.Duration
+}
+
+type Option func(g *gateway)
+
+// Время хранения ключей идемпотентности.
+func WithIdempotencyTTL(d time.Duration) Option {
+	return func(g *gateway) {
+		g.idempotencyTTL = d
+	}
 }
 
 func (g *gateway) CancelTask(ctx context.Context, req *api.CancelTaskRequest, res *api.CancelTaskResponse) error {
+	if err := g.authorizeTaskID(ctx, req.TaskID); err != nil {
+		return err
+	}
 	task, err := g.operator.Cancel(ctx, req.TaskID)
 	if err != nil {
 		if operErr, ok := err.(*operator.Error); ok {
@@ -38,36 +54,37 @@ func (g *gateway) CancelTask(ctx context.Context, req *api.CancelTaskRequest, re
 }
 
 func (g *gateway) CreateTask(ctx context.Context, req *api.CreateTaskRequest, res *api.CreateTaskResponse) error {
-	optask, err := g.factory.Construct(req.TaskType, req.Options)
+	if req.IdempotencyKey != "" {
+		return g.createIdempotent(ctx, req, res)
+	}
+	return g.createTask(ctx, req, res)
+}
+
+func (g *gateway) createTask(ctx context.Context, req *api.CreateTaskRequest, res *api.CreateTaskResponse) error {
+	optask, err := constructTask(ctx, g.factory, req)
 	if err != nil {
-		if factoryErr, ok := err.(*factory.Error); ok {
-			switch factoryErr.Code() {
-			case factory.ErrCodeBadInput, factory.ErrCodeUnknownTaskType:
-				return NewError(ErrCodeBadInput, factoryErr.Error())
-			}
-		}
 		return err
 	}
-	task, err := g.operator.Create(ctx, optask)
+	params, err := createParams(ctx, req)
 	if err != nil {
-		if operErr, ok := err.(*operator.Error); ok {
-			switch operErr.Code() {
-			case operator.ErrCodeBadInput:
-				return NewError(ErrCodeBadInput, operErr.Error())
-			case operator.ErrCodeNotFound:
-				return NewError(ErrCodeNotFound, operErr.Error())
-			}
-		}
 		return err
 	}
+	task, err := g.operator.Create(ctx, optask, params)
+	if err != nil {
+		return createError(err)
+	}
 	res.TaskID = int(task.ID)
 	res.CreatedAt = timing.Format(task.CreatedAt)
 	res.Options = optask.Options()
 	res.TaskType = optask.Type()
+	res.Status = taskApiStatus(*task)
 	return nil
 }
 
 func (g *gateway) DeleteTask(ctx context.Context, req *api.DeleteTaskRequest, res *api.DeleteTaskResponse) error {
+	if err := g.authorizeTaskID(ctx, req.TaskID); err != nil {
+		return err
+	}
 	err := g.operator.Delete(ctx, req.TaskID)
 	if err != nil {
 		if operErr, ok := err.(*operator.Error); ok {
@@ -85,83 +102,322 @@ func (g *gateway) DeleteTask(ctx context.Context, req *api.DeleteTaskRequest, re
 }
 
 func (g *gateway) GetTaskDetails(ctx context.Context, req *api.GetTaskDetailsRequest, res *api.GetTaskDetailsResponse) error {
-	task, err := g.repo.Find(ctx, uint64(req.TaskID))
+	task, err := g.findTask(ctx, uint64(req.TaskID))
 	if err != nil {
-		if repoErr, ok := err.(*repository.Error); ok {
-			if repoErr.Code() == repository.ErrCodeNotFound {
-				return NewError(ErrCodeNotFound, repoErr.Error())
-			}
-		}
 		return err
 	}
+	g.taskDetails(ctx, *task, res)
+	return nil
+}
+
+func (g *gateway) taskDetails(ctx context.Context, task repository.Task, res *api.GetTaskDetailsResponse) {
 	res.TaskID = int(task.ID)
 	res.Options = task.Options
 	res.TaskType = task.Type
 	res.CreatedAt = timing.Format(task.CreatedAt)
-	res.Status = taskApiStatus(*task)
+	res.Status = taskApiStatus(task)
+	if task.RunAt != 0 {
+		res.RunAt = timing.Format(task.RunAt)
+	}
+	if task.StartedAt != 0 {
+		res.StartedAt = timing.Format(task.StartedAt)
+	}
+	if task.Status == repository.StatusQueued {
+		res.QueuePosition, _ = g.operator.QueuePosition(ctx, task.ID)
+	}
 	if task.FinishedAt != 0 {
 		res.ExecutionTime = timing.Elapsed(task.FinishedAt, task.CreatedAt)
-		if task.Aborted {
+		switch task.Status {
+		case repository.StatusAborted:
 			res.AbortedAt = timing.Format(task.FinishedAt)
-		} else {
+		case repository.StatusFailed:
+			res.FailedAt = timing.Format(task.FinishedAt)
+		case repository.StatusInterrupted:
+			res.InterruptedAt = timing.Format(task.FinishedAt)
+		case repository.StatusTimedOut:
+			res.TimedOutAt = timing.Format(task.FinishedAt)
+		default:
 			res.ExecutedAt = timing.Format(task.FinishedAt)
 		}
 	} else {
 		res.ExecutionTime = timing.Elapsed(timing.Timestamp(), task.CreatedAt)
 	}
-	return nil
+	res.Error = task.Error
+	res.ErrorCode = task.ErrorCode
+	res.TimeoutSec = int(task.Timeout / time.Second)
+	if task.Deadline != 0 {
+		res.Deadline = timing.Format(task.Deadline)
+	}
+	res.AttemptCount = len(task.Attempts)
+	for i, attempt := range task.Attempts {
+		a := api.TaskAttempt{
+			Attempt:   i + 1,
+			StartedAt: timing.Format(attempt.StartedAt),
+			Error:     attempt.Error,
+			ErrorCode: attempt.ErrorCode,
+		}
+		if attempt.FinishedAt != 0 {
+			a.FinishedAt = timing.Format(attempt.FinishedAt)
+		}
+		res.Attempts = append(res.Attempts, a)
+	}
+	if task.NextAttemptAt != 0 {
+		res.NextAttemptAt = timing.Format(task.NextAttemptAt)
+	}
+	for _, id := range task.DependsOn {
+		res.DependsOn = append(res.DependsOn, int(id))
+	}
+	res.Priority = task.Priority
+	res.Labels = task.Labels
+	res.Owner = task.Owner
+	res.Namespace = task.NamespaceOrDefault()
+	if task.Progress != nil {
+		res.Progress = &api.TaskProgress{
+			Percent:   task.Progress.Percent,
+			Step:      task.Progress.Step,
+			Message:   task.Progress.Message,
+			UpdatedAt: timing.Format(task.Progress.UpdatedAt),
+		}
+	}
 }
 
 func (g *gateway) GetTaskResult(ctx context.Context, req *api.GetTaskResultRequest, res *api.GetTaskResultResponse) error {
-	task, err := g.repo.Find(ctx, uint64(req.TaskID))
+	task, err := g.findTask(ctx, uint64(req.TaskID))
 	if err != nil {
-		if repoErr, ok := err.(*repository.Error); ok {
-			if repoErr.Code() == repository.ErrCodeNotFound {
-				return NewError(ErrCodeNotFound, repoErr.Error())
-			}
-		}
 		return err
 	}
-	if task.Result == nil && task.Error == "" {
+	if task.Status != repository.StatusExecuted && task.Result == nil && task.Error == "" {
 		msg := fmt.Sprintf("задача с id %d не выполнена", req.TaskID)
 		return NewError(ErrCodeNotFound, msg)
 	}
 	res.TaskID = int(task.ID)
+	res.Status = taskApiStatus(*task)
 	res.Result = task.Result
 	res.Error = task.Error
+	res.ErrorCode = task.ErrorCode
+	return nil
+}
+
+func (g *gateway) WaitTask(ctx context.Context, req *api.WaitTaskRequest, res *api.WaitTaskResponse) error {
+	timeout := req.TimeoutSec
+	if timeout == 0 {
+		timeout = api.DefaultWaitTimeoutSec
+	}
+	if err := g.authorizeTaskID(ctx, uint64(req.TaskID)); err != nil {
+		return err
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+	task, err := g.operator.Wait(waitCtx, uint64(req.TaskID))
+	if task == nil {
+		if operErr, ok := err.(*operator.Error); ok {
+			if operErr.Code() == operator.ErrCodeNotFound {
+				return NewError(ErrCodeNotFound, operErr.Error())
+			}
+		}
+		return err
+	}
+	// Ожидание прервал сам запрос, а не истечение `timeout_sec`.
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	g.taskDetails(ctx, *task, &res.GetTaskDetailsResponse)
+	res.Completed = err == nil
+	res.Result = task.Result
 	return nil
 }
 
 func (g *gateway) ListTasks(ctx context.Context, req *api.ListTasksRequest, res *api.ListTasksResponse) error {
-	rTasks, err := g.repo.List(ctx)
+	q, err := listQuery(ctx, req)
 	if err != nil {
 		return err
 	}
-	tasks := make([]api.TaskSummary, 0, len(rTasks))
-	for _, task := range rTasks {
-		summary := api.TaskSummary{
-			TaskID:   int(task.ID),
-			TaskType: task.Type,
-			Status:   taskApiStatus(task),
+	return listTasks(ctx, g.repo, q, res)
+}
+
+func listTasks(ctx context.Context, repo repository.Repository, q repository.ListQuery, res *api.ListTasksResponse) error {
+	list, err := repo.List(ctx, q)
+	if err != nil {
+		if repoErr, ok := err.(*repository.Error); ok {
+			if repoErr.Code() == repository.ErrCodeBadInput {
+				return NewError(ErrCodeBadInput, repoErr.Error())
+			}
 		}
-		tasks = append(tasks, summary)
+		return err
+	}
+	res.Tasks = make([]api.TaskSummary, 0, len(list.Tasks))
+	for _, task := range list.Tasks {
+		res.Tasks = append(res.Tasks, taskSummary(task))
 	}
-	res.Tasks = tasks
+	res.NextCursor = list.NextCursor
 	return nil
 }
 
-func New(r repository.Repository, o operator.Operator, f factory.Factory) Gateway {
-	return &gateway{r, o, f}
+func taskSummary(task repository.Task) api.TaskSummary {
+	return api.TaskSummary{
+		TaskID:    int(task.ID),
+		TaskType:  task.Type,
+		Status:    taskApiStatus(task),
+		Priority:  task.Priority,
+		Labels:    task.Labels,
+		Owner:     task.Owner,
+		Namespace: task.NamespaceOrDefault(),
+	}
+}
+
+func listQuery(ctx context.Context, req *api.ListTasksRequest) (repository.ListQuery, error) {
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil {
+		return repository.ListQuery{}, NewError(ErrCodeBadInput, err.Error())
+	}
+	q := repository.ListQuery{
+		Types:    req.TaskType,
+		Selector: append(labels.FromMap(req.Labels), selector...),
+		Owner:    ownerFilter(ctx),
+		Limit:    req.Limit,
+		Cursor:   req.Cursor,
+	}
+	if req.Mine {
+		q.Owner = principalName(ctx)
+	}
+	if q.Limit == 0 {
+		q.Limit = api.DefaultListLimit
+	}
+	for _, status := range req.Status {
+		q.Statuses = append(q.Statuses, repository.Status(status))
+	}
+	if req.CreatedFrom != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedFrom)
+		if err != nil {
+			return q, NewError(ErrCodeBadInput, err.Error())
+		}
+		q.CreatedFrom = t.Unix()
+	}
+	if req.CreatedTo != "" {
+		t, err := time.Parse(time.RFC3339, req.CreatedTo)
+		if err != nil {
+			return q, NewError(ErrCodeBadInput, err.Error())
+		}
+		q.CreatedTo = t.Unix()
+	}
+	switch req.SortBy {
+	case api.TaskSortPriority:
+		q.SortBy = repository.SortByPriority
+		q.Desc = req.Order != api.SortOrderAsc
+	default:
+		q.SortBy = repository.SortByCreated
+		q.Desc = req.Order == api.SortOrderDesc
+	}
+	return q, nil
+}
+
+func New(r repository.Repository, o operator.Operator, f factory.Factory, opts ...Option) Gateway {
+	g := &gateway{
+		repo:           r,
+		operator:       o,
+		factory:        f,
+		idempotencyTTL: defaultIdempotencyTTL,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 func taskApiStatus(task repository.Task) api.TaskStatus {
-	status := api.TaskStatusCreated
-	if task.FinishedAt != 0 {
-		if task.Aborted {
-			status = api.TaskStatusAborted
-		} else {
-			status = api.TaskStatusExecuted
+	switch task.Status {
+	case repository.StatusRunning:
+		return api.TaskStatusRunning
+	case repository.StatusExecuted:
+		return api.TaskStatusExecuted
+	case repository.StatusAborted:
+		return api.TaskStatusAborted
+	case repository.StatusFailed:
+		return api.TaskStatusFailed
+	case repository.StatusInterrupted:
+		return api.TaskStatusInterrupted
+	case repository.StatusRetrying:
+		return api.TaskStatusRetrying
+	case repository.StatusTimedOut:
+		return api.TaskStatusTimedOut
+	case repository.StatusScheduled:
+		return api.TaskStatusScheduled
+	case repository.StatusBlocked:
+		return api.TaskStatusBlocked
+	}
+	return api.TaskStatusQueued
+}
+
+func constructTask(ctx context.Context, f factory.Factory, req *api.CreateTaskRequest) (operator.Task, error) {
+	if err := authorizeTaskType(ctx, req.TaskType); err != nil {
+		return nil, err
+	}
+	optask, err := f.Construct(req.TaskType, req.Options)
+	if err != nil {
+		if factoryErr, ok := err.(*factory.Error); ok {
+			switch factoryErr.Code() {
+			case factory.ErrCodeBadInput, factory.ErrCodeUnknownTaskType:
+				return nil, NewError(ErrCodeBadInput, factoryErr.Error())
+			}
 		}
+		return nil, err
+	}
+	return optask, nil
+}
+
+func createParams(ctx context.Context, req *api.CreateTaskRequest) (operator.CreateParams, error) {
+	params := operator.CreateParams{
+		Retry:   retryPolicy(req.Retry),
+		Timeout: time.Duration(req.TimeoutSec) * time.Second,
+		Owner:   principalName(ctx),
+	}
+	var err error
+	if req.Deadline != "" {
+		params.Deadline, err = time.Parse(time.RFC3339, req.Deadline)
+		if err != nil {
+			return params, NewError(ErrCodeBadInput, err.Error())
+		}
+	}
+	if req.RunAt != "" {
+		params.RunAt, err = time.Parse(time.RFC3339, req.RunAt)
+		if err != nil {
+			return params, NewError(ErrCodeBadInput, err.Error())
+		}
+	}
+	if req.DelaySec > 0 {
+		params.RunAt = time.Now().Add(time.Duration(req.DelaySec) * time.Second)
+	}
+	for _, id := range req.DependsOn {
+		params.DependsOn = append(params.DependsOn, uint64(id))
+	}
+	params.Priority = req.Priority
+	params.Labels = req.Labels
+	return params, nil
+}
+
+func createError(err error) error {
+	if operErr, ok := err.(*operator.Error); ok {
+		switch operErr.Code() {
+		case operator.ErrCodeBadInput:
+			return NewError(ErrCodeBadInput, operErr.Error())
+		case operator.ErrCodeNotFound:
+			return NewError(ErrCodeNotFound, operErr.Error())
+		case operator.ErrCodeQueueFull, operator.ErrCodeUnavailable:
+			return NewError(ErrCodeUnavailable, operErr.Error())
+		}
+	}
+	return err
+}
+
+func retryPolicy(p *api.RetryPolicy) *repository.RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	return &repository.RetryPolicy{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: time.Duration(p.InitialBackoffSec * float64(time.Second)),
+		Multiplier:     p.Multiplier,
+		Jitter:         p.Jitter,
+		RetryableCodes: p.RetryableErrors,
 	}
-	return status
 }
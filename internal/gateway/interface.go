@@ -13,4 +13,34 @@ type Gateway interface {
 	DeleteTask(context.Context, *api.DeleteTaskRequest, *api.DeleteTaskResponse) error
 	GetTaskDetails(context.Context, *api.GetTaskDetailsRequest, *api.GetTaskDetailsResponse) error
 	GetTaskResult(context.Context, *api.GetTaskResultRequest, *api.GetTaskResultResponse) error
+	WaitTask(context.Context, *api.WaitTaskRequest, *api.WaitTaskResponse) error
+	CreateTasks(context.Context, *api.CreateTasksRequest, *api.CreateTasksResponse) error
+	CancelTasks(context.Context, *api.CancelTasksRequest, *api.CancelTasksResponse) error
+	DeleteTasks(context.Context, *api.DeleteTasksRequest, *api.DeleteTasksResponse) error
+}
+
+// Точка входа апи расписаний.
+type Schedules interface {
+	Create(context.Context, *api.CreateScheduleRequest, *api.CreateScheduleResponse) error
+	List(context.Context, *api.ListSchedulesRequest, *api.ListSchedulesResponse) error
+	Pause(context.Context, *api.PauseScheduleRequest, *api.PauseScheduleResponse) error
+	Resume(context.Context, *api.ResumeScheduleRequest, *api.ResumeScheduleResponse) error
+	Delete(context.Context, *api.DeleteScheduleRequest, *api.DeleteScheduleResponse) error
+	ListRuns(context.Context, *api.ListScheduleRunsRequest, *api.ListScheduleRunsResponse) error
+}
+
+// Точка входа апи процессов из связанных задач.
+type Workflows interface {
+	Create(context.Context, *api.CreateWorkflowRequest, *api.CreateWorkflowResponse) error
+}
+
+// Точка входа апи администратора: задачи всех пространств имен.
+type Admin interface {
+	ListTasks(context.Context, *api.AdminListTasksRequest, *api.ListTasksResponse) error
+	ListNamespaces(context.Context, *api.ListNamespacesRequest, *api.ListNamespacesResponse) error
+}
+
+// Точка входа апи квот одновременно выполняемых задач.
+type Quotas interface {
+	Get(context.Context, *api.GetQuotasRequest, *api.GetQuotasResponse) error
 }
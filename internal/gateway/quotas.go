@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"context"
+	"task-api/api"
+	"task-api/internal/executor"
+	"task-api/internal/operator"
+	"task-api/pkg/webservice"
+)
+
+type quotas struct {
+	operator operator.Operator
+}
+
+func NewQuotas(o operator.Operator) Quotas {
+	return &quotas{o}
+}
+
+// Квоты ключей API других владельцев видны только администратору.
+// Квоты типов задач показываются для пространства имен запроса.
+func (g *quotas) Get(ctx context.Context, req *api.GetQuotasRequest, res *api.GetQuotasResponse) error {
+	p, authenticated := webservice.PrincipalFromContext(ctx)
+	res.Quotas = []api.Quota{}
+	for _, q := range g.operator.Quotas(ctx) {
+		if q.Scope == executor.QuotaScopeOwner && authenticated && !p.Admin && q.Name != p.Name {
+			continue
+		}
+		res.Quotas = append(res.Quotas, api.Quota{
+			Scope:     string(q.Scope),
+			Name:      q.Name,
+			Namespace: q.Namespace,
+			Limit:     q.Limit,
+			Running:   q.Running,
+			Queued:    q.Queued,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"task-api/api"
+	"task-api/internal/repository"
+	"task-api/internal/scheduler"
+	"task-api/pkg/timing"
+)
+
+type schedules struct {
+	scheduler scheduler.Scheduler
+	repo      repository.Repository
+}
+
+func NewSchedules(s scheduler.Scheduler, r repository.Repository) Schedules {
+	return &schedules{s, r}
+}
+
+func (g *schedules) Create(ctx context.Context, req *api.CreateScheduleRequest, res *api.CreateScheduleResponse) error {
+	if err := authorizeTaskType(ctx, req.TaskType); err != nil {
+		return err
+	}
+	schedule, err := g.scheduler.Create(ctx, req.Cron, req.TaskType, req.Options, principalName(ctx))
+	if err != nil {
+		return schedulerError(err)
+	}
+	res.Schedule = scheduleApi(*schedule)
+	return nil
+}
+
+func (g *schedules) List(ctx context.Context, req *api.ListSchedulesRequest, res *api.ListSchedulesResponse) error {
+	list, err := g.scheduler.List(ctx)
+	if err != nil {
+		return err
+	}
+	owner := ownerFilter(ctx)
+	res.Schedules = make([]api.Schedule, 0, len(list))
+	for _, schedule := range list {
+		if owner == "" || schedule.Owner == owner {
+			res.Schedules = append(res.Schedules, scheduleApi(schedule))
+		}
+	}
+	return nil
+}
+
+func (g *schedules) Pause(ctx context.Context, req *api.PauseScheduleRequest, res *api.PauseScheduleResponse) error {
+	if err := g.authorizeSchedule(ctx, uint64(req.ScheduleID)); err != nil {
+		return err
+	}
+	schedule, err := g.scheduler.Pause(ctx, uint64(req.ScheduleID))
+	if err != nil {
+		return schedulerError(err)
+	}
+	res.Schedule = scheduleApi(*schedule)
+	return nil
+}
+
+func (g *schedules) Resume(ctx context.Context, req *api.ResumeScheduleRequest, res *api.ResumeScheduleResponse) error {
+	if err := g.authorizeSchedule(ctx, uint64(req.ScheduleID)); err != nil {
+		return err
+	}
+	schedule, err := g.scheduler.Resume(ctx, uint64(req.ScheduleID))
+	if err != nil {
+		return schedulerError(err)
+	}
+	res.Schedule = scheduleApi(*schedule)
+	return nil
+}
+
+func (g *schedules) Delete(ctx context.Context, req *api.DeleteScheduleRequest, res *api.DeleteScheduleResponse) error {
+	if err := g.authorizeSchedule(ctx, uint64(req.ScheduleID)); err != nil {
+		return err
+	}
+	err := g.scheduler.Delete(ctx, uint64(req.ScheduleID))
+	if err != nil {
+		return schedulerError(err)
+	}
+	res.ScheduleID = req.ScheduleID
+	return nil
+}
+
+// История запусков доступна и после удаления расписания.
+func (g *schedules) ListRuns(ctx context.Context, req *api.ListScheduleRunsRequest, res *api.ListScheduleRunsResponse) error {
+	list, err := g.repo.List(ctx, repository.ListQuery{
+		ScheduleID: uint64(req.ScheduleID),
+		Owner:      ownerFilter(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	res.ScheduleID = req.ScheduleID
+	res.Runs = []api.TaskSummary{}
+	for _, task := range list.Tasks {
+		res.Runs = append(res.Runs, taskSummary(task))
+	}
+	return nil
+}
+
+// Проверяет, что расписание видно ключу API запроса. Чужие расписания для ключа
+// с доступом только к своим задачам не существуют.
+func (g *schedules) authorizeSchedule(ctx context.Context, scheduleID uint64) error {
+	owner := ownerFilter(ctx)
+	if owner == "" {
+		return nil
+	}
+	schedule, err := g.scheduler.Find(ctx, scheduleID)
+	if err != nil {
+		return schedulerError(err)
+	}
+	if schedule.Owner != owner {
+		return NewError(ErrCodeNotFound, fmt.Sprintf("расписание с id %d не найдено", scheduleID))
+	}
+	return nil
+}
+
+func schedulerError(err error) error {
+	if schedErr, ok := err.(*scheduler.Error); ok {
+		switch schedErr.Code() {
+		case scheduler.ErrCodeBadInput:
+			return NewError(ErrCodeBadInput, schedErr.Error())
+		case scheduler.ErrCodeNotFound:
+			return NewError(ErrCodeNotFound, schedErr.Error())
+		}
+	}
+	return err
+}
+
+func scheduleApi(s scheduler.Schedule) api.Schedule {
+	schedule := api.Schedule{
+		ScheduleID: int(s.ID),
+		Cron:       s.Cron,
+		TaskType:   s.TaskType,
+		Options:    s.Options,
+		Status:     api.ScheduleStatusActive,
+		CreatedAt:  timing.Format(s.CreatedAt),
+		LastTaskID: int(s.LastTaskID),
+		LastError:  s.LastError,
+		Owner:      s.Owner,
+	}
+	if s.Paused {
+		schedule.Status = api.ScheduleStatusPaused
+	}
+	if s.NextRunAt != 0 {
+		schedule.NextRunAt = timing.Format(s.NextRunAt)
+	}
+	if s.LastRunAt != 0 {
+		schedule.LastRunAt = timing.Format(s.LastRunAt)
+	}
+	return schedule
+}
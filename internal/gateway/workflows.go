@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"task-api/api"
+	"task-api/internal/factory"
+	"task-api/internal/operator"
+)
+
+type workflows struct {
+	operator operator.Operator
+	factory  factory.Factory
+}
+
+func NewWorkflows(o operator.Operator, f factory.Factory) Workflows {
+	return &workflows{o, f}
+}
+
+func (g *workflows) Create(ctx context.Context, req *api.CreateWorkflowRequest, res *api.CreateWorkflowResponse) error {
+	index := make(map[string]int, len(req.Tasks))
+	for i, task := range req.Tasks {
+		index[task.Key] = i
+	}
+	nodes := make([]operator.WorkflowNode, len(req.Tasks))
+	for i := range req.Tasks {
+		task := &req.Tasks[i]
+		optask, err := constructTask(ctx, g.factory, &task.CreateTaskRequest)
+		if err != nil {
+			return err
+		}
+		params, err := createParams(ctx, &task.CreateTaskRequest)
+		if err != nil {
+			return err
+		}
+		nodes[i] = operator.WorkflowNode{Task: optask, Params: params}
+		for _, key := range task.After {
+			j, ok := index[key]
+			if !ok {
+				msg := fmt.Sprintf("задача `%s`: неизвестная зависимость `%s`", task.Key, key)
+				return NewError(ErrCodeBadInput, msg)
+			}
+			nodes[i].DependsOn = append(nodes[i].DependsOn, j)
+		}
+	}
+	tasks, err := g.operator.CreateWorkflow(ctx, nodes)
+	if err != nil {
+		return createError(err)
+	}
+	res.Tasks = make([]api.WorkflowTaskSummary, 0, len(tasks))
+	for i, task := range tasks {
+		res.Tasks = append(res.Tasks, api.WorkflowTaskSummary{
+			Key:         req.Tasks[i].Key,
+			TaskSummary: taskSummary(*task),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package monitoring
+
+import (
+	"context"
+	"sync"
+	"task-api/internal/repository"
+	"task-api/pkg/metrics"
+	"time"
+)
+
+// Хранилище, считающее метрики задач при их изменении.
+type observedRepository struct {
+	repository.Repository
+	created  *metrics.Counter
+	finished *metrics.Counter
+	duration *metrics.Histogram
+
+	mu sync.Mutex
+	// Время начала выполняемых попыток задач.
+	started map[uint64]time.Time
+}
+
+var _ repository.Repository = (*observedRepository)(nil)
+
+// Оборачивает хранилище r и регистрирует в reg метрики задач:
+// созданные и завершенные задачи по типам и длительность выполнения попыток.
+func Observe(r repository.Repository, reg *metrics.Registry) repository.Repository {
+	return &observedRepository{
+		Repository: r,
+		created:    reg.Counter("tasks_created_total", "Число созданных задач по типам.", "task_type"),
+		finished: reg.Counter("tasks_finished_total",
+			"Число завершенных задач по типам и конечным статусам.", "task_type", "status"),
+		duration: reg.Histogram("task_execution_duration_seconds",
+			"Длительность попыток выполнения задач по типам и статусам после попытки.",
+			metrics.DefaultBuckets, "task_type", "status"),
+		started: make(map[uint64]time.Time),
+	}
+}
+
+// Число задач в очереди и выполняемых задач, например у исполнителя.
+type Load interface {
+	Load() (queued, running int)
+}
+
+// Регистрирует в reg показатели очереди и выполняемых задач.
+func ObserveLoad(reg *metrics.Registry, l Load) {
+	reg.GaugeFunc("tasks_queued", "Число задач в очереди исполнителя.", func() float64 {
+		queued, _ := l.Load()
+		return float64(queued)
+	})
+	reg.GaugeFunc("tasks_running", "Число выполняемых задач.", func() float64 {
+		_, running := l.Load()
+		return float64(running)
+	})
+}
+
+// Create implements repository.Repository.
+func (r *observedRepository) Create(ctx context.Context, task repository.Task) (*repository.Task, error) {
+	created, err := r.Repository.Create(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	r.created.Inc(created.Type)
+	return created, nil
+}
+
+// Update implements repository.Repository.
+func (r *observedRepository) Update(ctx context.Context, taskID uint64, update func(t repository.Task) (repository.Task, error)) (*repository.Task, error) {
+	var old repository.Task
+	updated, err := r.Repository.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
+		old = t
+		return update(t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Метрики учитывают только сохраненные изменения.
+	if updated.Status != old.Status {
+		r.transition(old, *updated)
+	}
+	return updated, nil
+}
+
+// Delete implements repository.Repository.
+func (r *observedRepository) Delete(ctx context.Context, taskID uint64) error {
+	if err := r.Repository.Delete(ctx, taskID); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.started, taskID)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *observedRepository) transition(old, t repository.Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.Status == repository.StatusRunning {
+		r.started[t.ID] = time.Now()
+	} else if old.Status == repository.StatusRunning {
+		// Попытки, начатые до перезапуска сервиса, не измеряются.
+		if start, ok := r.started[t.ID]; ok {
+			r.duration.Observe(time.Since(start).Seconds(), t.Type, string(t.Status))
+			delete(r.started, t.ID)
+		}
+	}
+	if t.Status.Terminal() {
+		r.finished.Inc(t.Type, string(t.Status))
+	}
+}
@@ -0,0 +1,78 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"task-api/internal/repository"
+	"task-api/pkg/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Хранилище, не сохраняющее изменения задач, например из-за ошибки записи журнала.
+type failingRepository struct {
+	repository.Repository
+}
+
+// Update implements repository.Repository.
+func (r failingRepository) Update(ctx context.Context, taskID uint64, update func(t repository.Task) (repository.Task, error)) (*repository.Task, error) {
+	task, err := r.Find(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := update(*task); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("запись журнала не удалась")
+}
+
+type load struct{}
+
+func (load) Load() (int, int) {
+	return 2, 1
+}
+
+func TestObserve(t *testing.T) {
+	reg := metrics.NewRegistry()
+	repo := Observe(repository.New(), reg)
+	ObserveLoad(reg, load{})
+	ctx := context.Background()
+
+	task, err := repo.Create(ctx, repository.Task{Type: "waiting", Status: repository.StatusQueued})
+	assert.NoError(t, err)
+	for _, status := range []repository.Status{repository.StatusRunning, repository.StatusExecuted} {
+		_, err = repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+			t.Status = status
+			return t, nil
+		})
+		assert.NoError(t, err)
+	}
+
+	var b strings.Builder
+	assert.NoError(t, reg.Write(&b))
+	out := b.String()
+	assert.Contains(t, out, `tasks_created_total{task_type="waiting"} 1`)
+	assert.Contains(t, out, `tasks_finished_total{task_type="waiting",status="executed"} 1`)
+	assert.Contains(t, out, `task_execution_duration_seconds_count{task_type="waiting",status="executed"} 1`)
+	assert.Contains(t, out, "tasks_queued 2\n")
+	assert.Contains(t, out, "tasks_running 1\n")
+}
+
+func TestObserveFailedUpdate(t *testing.T) {
+	reg := metrics.NewRegistry()
+	repo := Observe(failingRepository{repository.New()}, reg)
+	ctx := context.Background()
+
+	task, _ := repo.Create(ctx, repository.Task{Type: "waiting", Status: repository.StatusRunning})
+	_, err := repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusExecuted
+		return t, nil
+	})
+	assert.Error(t, err)
+
+	var b strings.Builder
+	assert.NoError(t, reg.Write(&b))
+	assert.NotContains(t, b.String(), "tasks_finished_total{")
+}
@@ -5,6 +5,9 @@ type ErrCode int
 const (
 	ErrCodeBadInput ErrCode = iota
 	ErrCodeNotFound
+	ErrCodeQueueFull
+	// Оператор остановлен и не принимает задачи.
+	ErrCodeUnavailable
 )
 
 type Error struct {
@@ -0,0 +1,114 @@
+package operator
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Код ошибки задачи, завершенной из-за неуспешной зависимости.
+const ErrorCodeDependencyFailed = "dependency_failed"
+
+// Граф зависимостей задач, ожидающих исполнения.
+type graph struct {
+	mu sync.Mutex
+	// Задачи, ожидающие завершения задачи-ключа.
+	dependents map[uint64][]uint64
+	// Число незавершенных зависимостей задачи.
+	remaining map[uint64]int
+}
+
+func newGraph() graph {
+	return graph{
+		dependents: make(map[uint64][]uint64),
+		remaining:  make(map[uint64]int),
+	}
+}
+
+// Уменьшает счетчик задачи. Возвращает true, если задача готова к исполнению.
+func (g *graph) release(taskID uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n, ok := g.remaining[taskID]
+	if !ok {
+		return false
+	}
+	if n > 1 {
+		g.remaining[taskID] = n - 1
+		return false
+	}
+	delete(g.remaining, taskID)
+	return true
+}
+
+// Убирает заблокированную задачу из графа.
+// Возвращает false, если задача в графе не ожидает.
+func (g *graph) drop(taskID uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.remaining[taskID]; !ok {
+		return false
+	}
+	delete(g.remaining, taskID)
+	return true
+}
+
+// Отмечает завершение задачи. При успехе возвращает зависимые задачи,
+// готовые к исполнению, иначе - зависимые задачи, которые надо завершить.
+func (g *graph) resolve(taskID uint64, ok bool) (ready, broken []uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	dependents := g.dependents[taskID]
+	delete(g.dependents, taskID)
+	for _, id := range dependents {
+		n, waiting := g.remaining[id]
+		if !waiting {
+			continue
+		}
+		if !ok {
+			delete(g.remaining, id)
+			broken = append(broken, id)
+			continue
+		}
+		if n > 1 {
+			g.remaining[id] = n - 1
+			continue
+		}
+		delete(g.remaining, id)
+		ready = append(ready, id)
+	}
+	return ready, broken
+}
+
+// Упорядочивает узлы процесса так, что зависимости идут раньше зависимых.
+func topoSort(nodes []WorkflowNode) ([]int, error) {
+	indegree := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+	for i, node := range nodes {
+		for _, j := range node.DependsOn {
+			if j < 0 || j >= len(nodes) {
+				msg := fmt.Sprintf("неизвестная зависимость %d", j)
+				return nil, NewError(ErrCodeBadInput, msg)
+			}
+			indegree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+	order := make([]int, 0, len(nodes))
+	for i, n := range indegree {
+		if n == 0 {
+			order = append(order, i)
+		}
+	}
+	for k := 0; k < len(order); k++ {
+		for _, i := range dependents[order[k]] {
+			indegree[i]--
+			if indegree[i] == 0 {
+				order = append(order, i)
+			}
+		}
+	}
+	if len(order) != len(nodes) {
+		return nil, NewError(ErrCodeBadInput, "зависимости задач образуют цикл")
+	}
+	return order, nil
+}
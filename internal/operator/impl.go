@@ -2,38 +2,161 @@ package operator
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"task-api/internal/executor"
 	"task-api/internal/repository"
+	"task-api/pkg/syncmap"
 	"task-api/pkg/timing"
+	"time"
 )
 
 type operator struct {
-	repo repository.Repository
-	exec executor.Executor
+	repo        repository.Repository
+	exec        executor.Executor
+	constructor Constructor
+
+	// Задачи, которые еще могут быть переданы исполнителю.
+	tasks syncmap.Map[uint64, *trackedTask]
+
+	mu     sync.Mutex
+	timers map[uint64]*time.Timer
+
+	graph graph
+
+	waitMu  sync.Mutex
+	waiters map[uint64][]chan struct{}
+
+	// Состояние получателя результатов исполнителя для проверки Health.
+	consuming     atomic.Bool
+	handlingSince atomic.Int64
+	stallTimeout  time.Duration
+	// Закрывается, когда получатель результатов завершается.
+	consumed chan struct{}
+
+	stopping atomic.Bool
+}
+
+type Option func(o *operator)
+
+// Время, дольше которого результат задачи не должен ждать обработки.
+const defaultStallTimeout = 10 * time.Second
+
+// Время обработки или ожидания результата задачи, после которого Health
+// считает получателя результатов зависшим.
+func WithStallTimeout(d time.Duration) Option {
+	return func(o *operator) {
+		o.stallTimeout = d
+	}
+}
+
+// Позволяет восстановить из хранилища отложенные задачи при запуске.
+func WithConstructor(c Constructor) Option {
+	return func(o *operator) {
+		o.constructor = c
+	}
 }
 
-func New(r repository.Repository, e executor.Executor) *operator {
-	o := &operator{r, e}
-	o.consumeResults(context.Background())
+func New(r repository.Repository, e executor.Executor, opts ...Option) *operator {
+	o := &operator{
+		repo:         r,
+		exec:         e,
+		timers:       make(map[uint64]*time.Timer),
+		graph:        newGraph(),
+		waiters:      make(map[uint64][]chan struct{}),
+		stallTimeout: defaultStallTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	ctx := context.Background()
+	o.consumeResults(ctx)
+	o.restoreScheduled(ctx)
 	return o
 }
 
+// Останавливает таймеры отложенных задач. Задачи остаются в хранилище
+// в статусе scheduled и восстанавливаются при следующем запуске.
+func (o *operator) Stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for taskID, timer := range o.timers {
+		timer.Stop()
+		delete(o.timers, taskID)
+	}
+}
+
 var _ Operator = (*operator)(nil)
 
-// Cancel implements Operator.
-func (h *operator) Cancel(ctx context.Context, taskID uint64) (*repository.Task, error) {
-	err := h.exec.Cancel(ctx, taskID)
+// Останавливает оператор: новые задачи не принимаются, выполняемые задачи
+// завершаются до отмены ctx, обработка результатов прекращается, а остальные
+// незавершенные задачи получают статус interrupted. Отложенные задачи остаются
+// в статусе scheduled и восстанавливаются при следующем запуске.
+func (o *operator) Shutdown(ctx context.Context) error {
+	o.stopping.Store(true)
+	o.Stop()
+	o.exec.Shutdown(ctx)
+	<-o.consumed
+	// Таймеры повторов, взведенные при обработке последних результатов.
+	o.Stop()
+	return o.interruptUnfinished()
+}
+
+func (o *operator) interruptUnfinished() error {
+	ctx := context.Background()
+	list, err := o.repo.List(ctx, repository.ListQuery{})
 	if err != nil {
-		if execError, ok := err.(*executor.Error); ok {
-			if execError.Code() == executor.ErrCodeBadInput {
-				return nil, NewError(ErrCodeBadInput, execError.Error())
+		return err
+	}
+	now := timing.Timestamp()
+	for _, task := range list.Tasks {
+		if !task.Status.Interruptible() {
+			continue
+		}
+		_, err := o.repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+			if t.Status.Interruptible() {
+				t.Interrupt(now)
 			}
+			return t, nil
+		})
+		if err != nil {
+			return err
 		}
+		o.tasks.Delete(task.ID)
+		o.notify(task.ID)
+	}
+	return nil
+}
+
+// Cancel implements Operator.
+func (h *operator) Cancel(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx = context.WithoutCancel(ctx)
+	// Задача чужого пространства имен не должна быть остановлена.
+	if _, err := h.find(ctx, taskID); err != nil {
 		return nil, err
 	}
+	if !h.stopTimer(taskID) && !h.graph.drop(taskID) {
+		err := h.exec.Cancel(ctx, taskID)
+		if err != nil {
+			if execError, ok := err.(*executor.Error); ok {
+				if execError.Code() == executor.ErrCodeBadInput {
+					return nil, NewError(ErrCodeBadInput, execError.Error())
+				}
+			}
+			return nil, err
+		}
+	}
+	h.tasks.Delete(taskID)
 	task, err := h.repo.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
 		t.FinishedAt = timing.Timestamp()
-		t.Aborted = true
+		t.Status = repository.StatusAborted
+		t.NextAttemptAt = 0
 		return t, nil
 	})
 	if err != nil {
@@ -44,29 +167,353 @@ func (h *operator) Cancel(ctx context.Context, taskID uint64) (*repository.Task,
 		}
 		return nil, err
 	}
+	h.resolve(ctx, taskID, repository.StatusAborted)
 	return task, nil
 }
 
 // Create implements Operator.
-func (o *operator) Create(ctx context.Context, t Task) (*repository.Task, error) {
-	task, err := o.repo.Create(ctx, repository.Task{
-		Type:      t.Type(),
-		CreatedAt: timing.Timestamp(),
-		Options:   t.Options(),
+func (o *operator) Create(ctx context.Context, t Task, params CreateParams) (*repository.Task, error) {
+	tasks, err := o.CreateWorkflow(ctx, []WorkflowNode{{Task: t, Params: params}})
+	if err != nil {
+		return nil, err
+	}
+	return tasks[0], nil
+}
+
+// CreateWorkflow implements Operator.
+func (o *operator) CreateWorkflow(ctx context.Context, nodes []WorkflowNode) ([]*repository.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if o.stopping.Load() {
+		return nil, NewError(ErrCodeUnavailable, "сервис останавливается и не принимает новые задачи")
+	}
+	// Начатое создание доводится до конца, даже если запрос отменен.
+	ctx = context.WithoutCancel(ctx)
+	order, err := topoSort(nodes)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]CreateParams, len(nodes))
+	for i, node := range nodes {
+		if params[i], err = normalizeParams(node.Params); err != nil {
+			return nil, err
+		}
+	}
+	ids, err := o.createBlocked(ctx, nodes, params, order)
+	if err != nil {
+		return nil, err
+	}
+	// Снимаем барьер: задачи без незавершенных зависимостей уходят на исполнение.
+	for _, i := range order {
+		if err := o.release(ctx, ids[i]); err != nil {
+			o.rollback(ctx, ids)
+			if execErr, ok := err.(*executor.Error); ok {
+				switch execErr.Code() {
+				case executor.ErrCodeQueueFull:
+					return nil, NewError(ErrCodeQueueFull, execErr.Error())
+				case executor.ErrCodeStopped:
+					return nil, NewError(ErrCodeUnavailable, execErr.Error())
+				}
+			}
+			return nil, err
+		}
+	}
+	tasks := make([]*repository.Task, len(nodes))
+	for i, id := range ids {
+		if tasks[i], err = o.repo.Find(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// Проверяет параметры создания задачи и заполняет значения по умолчанию.
+func normalizeParams(params CreateParams) (CreateParams, error) {
+	retry, err := normalizeRetry(params.Retry)
+	if err != nil {
+		return params, err
+	}
+	params.Retry = retry
+	if params.Timeout < 0 {
+		return params, NewError(ErrCodeBadInput, "время выполнения задачи не может быть отрицательным")
+	}
+	now := time.Now()
+	if !params.Deadline.IsZero() && !params.Deadline.After(now) {
+		return params, NewError(ErrCodeBadInput, "крайний срок выполнения задачи уже прошел")
+	}
+	if params.RunAt.After(now) && !params.Deadline.IsZero() && params.Deadline.Before(params.RunAt) {
+		return params, NewError(ErrCodeBadInput, "крайний срок выполнения задачи раньше времени запуска")
+	}
+	return params, nil
+}
+
+// Сохраняет задачи процесса в порядке зависимостей и регистрирует их в графе.
+// Каждая задача удерживается барьером, пока процесс не создан целиком.
+func (o *operator) createBlocked(ctx context.Context, nodes []WorkflowNode, params []CreateParams, order []int) ([]uint64, error) {
+	o.graph.mu.Lock()
+	defer o.graph.mu.Unlock()
+	ids := make([]uint64, len(nodes))
+	var created []uint64
+	cleanup := func() {
+		for _, id := range created {
+			delete(o.graph.remaining, id)
+			o.tasks.Delete(id)
+			_ = o.repo.Delete(ctx, id)
+		}
+	}
+	now := time.Now()
+	for _, i := range order {
+		node, p := nodes[i], params[i]
+		var pending []uint64
+		for _, depID := range p.DependsOn {
+			dep, err := o.repo.Find(ctx, depID)
+			if err != nil {
+				cleanup()
+				msg := fmt.Sprintf("зависимость с id %d не найдена", depID)
+				return nil, NewError(ErrCodeBadInput, msg)
+			}
+			switch dep.Status {
+			case repository.StatusExecuted:
+			case repository.StatusFailed, repository.StatusAborted, repository.StatusTimedOut, repository.StatusInterrupted:
+				cleanup()
+				msg := fmt.Sprintf("зависимость с id %d завершилась со статусом %s", depID, dep.Status)
+				return nil, NewError(ErrCodeBadInput, msg)
+			default:
+				pending = append(pending, depID)
+			}
+		}
+		dependsOn := slices.Clone(p.DependsOn)
+		for _, j := range node.DependsOn {
+			dependsOn = append(dependsOn, ids[j])
+			pending = append(pending, ids[j])
+		}
+		newTask := repository.Task{
+			Type:       node.Task.Type(),
+			Status:     repository.StatusQueued,
+			CreatedAt:  timing.Timestamp(),
+			Options:    node.Task.Options(),
+			Timeout:    p.Timeout,
+			Retry:      p.Retry,
+			ScheduleID: p.ScheduleID,
+			DependsOn:  dependsOn,
+			Priority:   p.Priority,
+			Labels:     maps.Clone(p.Labels),
+			Owner:      p.Owner,
+		}
+		if !p.Deadline.IsZero() {
+			newTask.Deadline = p.Deadline.UTC().Unix()
+		}
+		if p.RunAt.After(now) {
+			newTask.Status = repository.StatusScheduled
+			newTask.RunAt = p.RunAt.UTC().Unix()
+		}
+		if len(pending) > 0 {
+			newTask.Status = repository.StatusBlocked
+		}
+		task, err := o.repo.Create(ctx, newTask)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		ids[i] = task.ID
+		created = append(created, task.ID)
+		o.tasks.Set(task.ID, &trackedTask{
+			Task:  node.Task,
+			id:    task.ID,
+			repo:  o.repo,
+			opts:  executeOptions(*task),
+			runAt: p.RunAt,
+		})
+		for _, depID := range pending {
+			o.graph.dependents[depID] = append(o.graph.dependents[depID], task.ID)
+		}
+		o.graph.remaining[task.ID] = len(pending) + 1
+	}
+	return ids, nil
+}
+
+// Отменяет частично созданный процесс.
+func (o *operator) rollback(ctx context.Context, ids []uint64) {
+	for _, id := range ids {
+		if !o.stopTimer(id) && !o.graph.drop(id) {
+			_ = o.exec.Cancel(ctx, id)
+		}
+		o.tasks.Delete(id)
+		_ = o.repo.Delete(ctx, id)
+	}
+}
+
+// Уменьшает счетчик незавершенных зависимостей задачи
+// и передает ее на исполнение, когда он обнуляется.
+func (o *operator) release(ctx context.Context, taskID uint64) error {
+	if !o.graph.release(taskID) {
+		return nil
+	}
+	return o.submit(ctx, taskID)
+}
+
+// Передает исполнителю задачу без незавершенных зависимостей
+// или взводит таймер, если время ее запуска еще не наступило.
+func (o *operator) submit(ctx context.Context, taskID uint64) error {
+	tracked, ok := o.tasks.Get(taskID)
+	if !ok {
+		return nil
+	}
+	scheduled := tracked.runAt.After(time.Now())
+	task, err := o.repo.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
+		switch t.Status {
+		case repository.StatusBlocked, repository.StatusQueued, repository.StatusScheduled:
+			if scheduled {
+				t.Status = repository.StatusScheduled
+			} else {
+				t.Status = repository.StatusQueued
+			}
+		}
+		return t, nil
 	})
+	if err != nil {
+		return err
+	}
+	switch task.Status {
+	case repository.StatusScheduled:
+		o.startTimer(taskID, time.Until(tracked.runAt), o.dispatch)
+	case repository.StatusQueued:
+		return o.exec.Execute(ctx, taskID, tracked, tracked.opts...)
+	}
+	return nil
+}
+
+// Сообщает зависимым задачам о завершении задачи taskID со статусом status.
+// Успешное завершение разблокирует их, любое другое - завершает каскадом.
+func (o *operator) resolve(ctx context.Context, taskID uint64, status repository.Status) {
+	o.notify(taskID)
+	ready, broken := o.graph.resolve(taskID, status == repository.StatusExecuted)
+	for _, id := range ready {
+		if err := o.submit(ctx, id); err != nil {
+			o.fail(ctx, id, err)
+		}
+	}
+	for _, id := range broken {
+		o.tasks.Delete(id)
+		cascaded := repository.StatusFailed
+		if status == repository.StatusAborted {
+			cascaded = repository.StatusAborted
+		}
+		_, err := o.repo.Update(ctx, id, func(t repository.Task) (repository.Task, error) {
+			t.Status = cascaded
+			t.FinishedAt = timing.Timestamp()
+			t.Error = fmt.Sprintf("зависимость с id %d завершилась со статусом %s", taskID, status)
+			t.ErrorCode = ErrorCodeDependencyFailed
+			return t, nil
+		})
+		if err == nil {
+			o.resolve(ctx, id, cascaded)
+		}
+	}
+}
+
+// Wait implements Operator.
+func (o *operator) Wait(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	done := make(chan struct{})
+	o.waitMu.Lock()
+	o.waiters[taskID] = append(o.waiters[taskID], done)
+	o.waitMu.Unlock()
+	defer o.unwait(taskID, done)
+
+	// Подписка оформлена до чтения: завершение между ними не потеряется.
+	task, err := o.find(ctx, taskID)
 	if err != nil {
 		return nil, err
 	}
-	err = o.exec.Execute(ctx, task.ID, t)
+	if task.Status.Terminal() {
+		return task, nil
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Состояние на момент отмены ожидания.
+		task, err = o.repo.Find(context.WithoutCancel(ctx), taskID)
+		if err != nil {
+			return nil, ctx.Err()
+		}
+		return task, ctx.Err()
+	}
+	// Задача уже завершена: отмена ctx в этот момент не должна помешать ее прочитать.
+	task, err = o.repo.Find(context.WithoutCancel(ctx), taskID)
 	if err != nil {
+		if repoErr, ok := err.(*repository.Error); ok && repoErr.Code() == repository.ErrCodeNotFound {
+			// Задача удалена: ожидать больше нечего.
+			msg := fmt.Sprintf("задача с id %d удалена", taskID)
+			return nil, NewError(ErrCodeNotFound, msg)
+		}
 		return nil, err
 	}
 	return task, nil
 }
 
+// Находит задачу, видимую в пространстве имен запроса.
+func (o *operator) find(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	task, err := o.repo.Find(ctx, taskID)
+	if err != nil {
+		if repoErr, ok := err.(*repository.Error); ok {
+			if repoErr.Code() == repository.ErrCodeNotFound {
+				return nil, NewError(ErrCodeNotFound, repoErr.Error())
+			}
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// Будит всех, кто ждет завершения задачи.
+func (o *operator) notify(taskID uint64) {
+	o.waitMu.Lock()
+	defer o.waitMu.Unlock()
+	for _, done := range o.waiters[taskID] {
+		close(done)
+	}
+	delete(o.waiters, taskID)
+}
+
+func (o *operator) unwait(taskID uint64, done chan struct{}) {
+	o.waitMu.Lock()
+	defer o.waitMu.Unlock()
+	waiters := slices.DeleteFunc(o.waiters[taskID], func(c chan struct{}) bool { return c == done })
+	if len(waiters) == 0 {
+		delete(o.waiters, taskID)
+	} else {
+		o.waiters[taskID] = waiters
+	}
+}
+
+// QueuePosition implements Operator.
+func (o *operator) QueuePosition(ctx context.Context, taskID uint64) (int, bool) {
+	return o.exec.QueuePosition(ctx, taskID)
+}
+
+// Quotas implements Operator.
+func (o *operator) Quotas(ctx context.Context) []executor.Quota {
+	ns, ok := repository.NamespaceFromContext(ctx)
+	if !ok {
+		ns = repository.DefaultNamespace
+	}
+	return o.exec.Quotas(ctx, ns)
+}
+
 // Delete implements Operator.
 func (t *operator) Delete(ctx context.Context, taskID uint64) error {
-	_ = t.exec.Cancel(ctx, taskID)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	if _, err := t.find(ctx, taskID); err != nil {
+		return err
+	}
+	if !t.stopTimer(taskID) && !t.graph.drop(taskID) {
+		_ = t.exec.Cancel(ctx, taskID)
+	}
+	t.tasks.Delete(taskID)
 	err := t.repo.Delete(ctx, taskID)
 	if err != nil {
 		if repoErr, ok := err.(*repository.Error); ok {
@@ -76,17 +523,263 @@ func (t *operator) Delete(ctx context.Context, taskID uint64) error {
 		}
 		return err
 	}
+	t.resolve(ctx, taskID, repository.StatusAborted)
 	return nil
 }
 
 func (o *operator) consumeResults(ctx context.Context) {
+	o.consuming.Store(true)
+	o.consumed = make(chan struct{})
 	go func() {
+		defer close(o.consumed)
+		defer o.consuming.Store(false)
 		for result := range o.exec.Results(ctx) {
-			o.repo.Update(ctx, result.TaskID, func(t repository.Task) (repository.Task, error) {
-				t.FinishedAt = timing.Timestamp()
-				t.Result = result.Data
-				return t, nil
-			})
+			o.handlingSince.Store(time.Now().UnixNano())
+			o.handleResult(ctx, result)
+			o.handlingSince.Store(0)
 		}
 	}()
 }
+
+// Проверяет, что результаты исполнителя обрабатываются: получатель работает,
+// не завис на одном результате и результаты не копятся дольше stallTimeout.
+func (o *operator) Health(ctx context.Context) error {
+	if !o.consuming.Load() {
+		return fmt.Errorf("получатель результатов исполнителя остановлен")
+	}
+	if since := o.handlingSince.Load(); since != 0 {
+		if d := time.Since(time.Unix(0, since)); d > o.stallTimeout {
+			return fmt.Errorf("обработка результата задачи длится %s", d.Round(time.Second))
+		}
+	}
+	if n, age := o.exec.ResultsBacklog(ctx); age > o.stallTimeout {
+		return fmt.Errorf("%d результатов задач ждут обработки дольше %s", n, age.Round(time.Second))
+	}
+	return nil
+}
+
+func (o *operator) handleResult(ctx context.Context, result executor.TaskResult) {
+	var backoff time.Duration
+	retry := false
+	// Таймер повтора взводится под той же блокировкой, что и смена статуса:
+	// иначе Cancel между ними не найдет таймер, а задача все равно повторится.
+	o.mu.Lock()
+	task, err := o.repo.Update(ctx, result.TaskID, func(t repository.Task) (repository.Task, error) {
+		if t.Status != repository.StatusRunning {
+			return t, nil
+		}
+		now := timing.Timestamp()
+		if n := len(t.Attempts); n > 0 {
+			t.Attempts = slices.Clone(t.Attempts)
+			t.Attempts[n-1].FinishedAt = now
+			if result.Error != nil {
+				t.Attempts[n-1].Error = result.Error.Error()
+				t.Attempts[n-1].ErrorCode = executor.FailureCode(result.Error)
+			}
+		}
+		if result.Error != nil {
+			t.Error = result.Error.Error()
+			t.ErrorCode = executor.FailureCode(result.Error)
+			if shouldRetry(t.Retry, t.ErrorCode, len(t.Attempts)) {
+				retry = true
+				backoff = retryBackoff(*t.Retry, len(t.Attempts))
+				t.Status = repository.StatusRetrying
+				t.NextAttemptAt = time.Now().Add(backoff).UTC().Unix()
+				return t, nil
+			}
+			if t.ErrorCode == executor.FailureCodeTimeout {
+				t.Status = repository.StatusTimedOut
+			} else {
+				t.Status = repository.StatusFailed
+			}
+		} else {
+			t.Status = repository.StatusExecuted
+			t.Result = result.Data
+			t.Error = ""
+			t.ErrorCode = ""
+		}
+		t.FinishedAt = now
+		return t, nil
+	})
+	if err == nil && retry {
+		o.armTimer(task.ID, backoff, o.dispatch)
+	}
+	o.mu.Unlock()
+	if err != nil || retry {
+		return
+	}
+	if task.FinishedAt != 0 {
+		o.tasks.Delete(task.ID)
+		o.resolve(ctx, task.ID, task.Status)
+	}
+}
+
+// Передает исполнителю задачу, ожидавшую запуска или повтора по таймеру.
+func (o *operator) dispatch(taskID uint64) {
+	ctx := context.Background()
+	tracked, ok := o.tasks.Get(taskID)
+	if !ok {
+		return
+	}
+	requeued := false
+	_, err := o.repo.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
+		if t.Status == repository.StatusRetrying || t.Status == repository.StatusScheduled {
+			t.Status = repository.StatusQueued
+			t.NextAttemptAt = 0
+			requeued = true
+		}
+		return t, nil
+	})
+	if err != nil || !requeued {
+		return
+	}
+	err = o.exec.Execute(ctx, taskID, tracked, tracked.opts...)
+	if err != nil {
+		o.fail(ctx, taskID, err)
+	}
+}
+
+func (o *operator) fail(ctx context.Context, taskID uint64, err error) {
+	// Задачу, которую не принял остановленный исполнитель, прерывает Shutdown.
+	if execErr, ok := err.(*executor.Error); ok && execErr.Code() == executor.ErrCodeStopped {
+		return
+	}
+	o.tasks.Delete(taskID)
+	_, updErr := o.repo.Update(ctx, taskID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusFailed
+		t.FinishedAt = timing.Timestamp()
+		t.Error = err.Error()
+		t.ErrorCode = executor.FailureCodeFailed
+		return t, nil
+	})
+	if updErr == nil {
+		o.resolve(ctx, taskID, repository.StatusFailed)
+	}
+}
+
+// Заново взводит таймеры задач, запланированных до остановки сервиса.
+func (o *operator) restoreScheduled(ctx context.Context) {
+	if o.constructor == nil {
+		return
+	}
+	list, err := o.repo.List(ctx, repository.ListQuery{
+		Statuses: []repository.Status{repository.StatusScheduled},
+	})
+	if err != nil {
+		return
+	}
+	for _, task := range list.Tasks {
+		t, err := o.constructor.Construct(task.Type, task.Options)
+		if err != nil {
+			o.fail(ctx, task.ID, err)
+			continue
+		}
+		runAt := time.Unix(task.RunAt, 0)
+		tracked := &trackedTask{Task: t, id: task.ID, repo: o.repo, opts: executeOptions(task), runAt: runAt}
+		o.tasks.Set(task.ID, tracked)
+		o.startTimer(task.ID, time.Until(runAt), o.dispatch)
+	}
+}
+
+func executeOptions(task repository.Task) []executor.ExecuteOption {
+	opts := []executor.ExecuteOption{executor.WithQuotaKeys(quotaKeys(task)...)}
+	if task.Priority != 0 {
+		opts = append(opts, executor.WithPriority(task.Priority))
+	}
+	if task.Timeout > 0 {
+		opts = append(opts, executor.WithTimeout(task.Timeout))
+	}
+	if task.Deadline != 0 {
+		opts = append(opts, executor.WithDeadline(time.Unix(task.Deadline, 0)))
+	}
+	return opts
+}
+
+// Квоты исполнителя, на которые расходуется задача.
+func quotaKeys(task repository.Task) []executor.QuotaKey {
+	keys := []executor.QuotaKey{{
+		Scope:     executor.QuotaScopeTaskType,
+		Name:      task.Type,
+		Namespace: task.NamespaceOrDefault(),
+	}}
+	if task.Owner != "" {
+		keys = append(keys, executor.QuotaKey{Scope: executor.QuotaScopeOwner, Name: task.Owner})
+	}
+	return keys
+}
+
+// Запускает отложенное действие над задачей. Действие выполняется
+// под блокировкой, поэтому не пересекается с stopTimer.
+func (o *operator) startTimer(taskID uint64, d time.Duration, fn func(taskID uint64)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.armTimer(taskID, d, fn)
+}
+
+// То же, что startTimer, но блокировку держит вызывающий.
+func (o *operator) armTimer(taskID uint64, d time.Duration, fn func(taskID uint64)) {
+	o.timers[taskID] = time.AfterFunc(d, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if _, ok := o.timers[taskID]; !ok {
+			return
+		}
+		delete(o.timers, taskID)
+		fn(taskID)
+	})
+}
+
+// Отменяет отложенное действие. Возвращает false, если его не было.
+func (o *operator) stopTimer(taskID uint64) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	timer, ok := o.timers[taskID]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(o.timers, taskID)
+	return true
+}
+
+// Обертка над задачей, которая отмечает в хранилище начало выполнения.
+type trackedTask struct {
+	Task
+	id    uint64
+	repo  repository.Repository
+	opts  []executor.ExecuteOption
+	runAt time.Time
+}
+
+func (t *trackedTask) Execute(ctx context.Context) (any, error) {
+	attempt := 0
+	t.repo.Update(ctx, t.id, func(task repository.Task) (repository.Task, error) {
+		if task.Status == repository.StatusQueued {
+			now := timing.Timestamp()
+			task.Status = repository.StatusRunning
+			if task.StartedAt == 0 {
+				task.StartedAt = now
+			}
+			task.Attempts = append(slices.Clone(task.Attempts), repository.Attempt{StartedAt: now})
+			task.Progress = nil
+		}
+		attempt = len(task.Attempts)
+		return task, nil
+	})
+	ctx = executor.WithReporter(ctx, func(p executor.Progress) {
+		t.repo.Update(ctx, t.id, func(task repository.Task) (repository.Task, error) {
+			// Прогресс брошенной по таймауту попытки не перезаписывает следующую.
+			if task.Status != repository.StatusRunning || len(task.Attempts) != attempt {
+				return task, nil
+			}
+			task.Progress = &repository.Progress{
+				Percent:   p.Percent,
+				Step:      p.Step,
+				Message:   p.Message,
+				UpdatedAt: timing.Timestamp(),
+			}
+			return task, nil
+		})
+	})
+	return t.Task.Execute(ctx)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"task-api/internal/executor"
 	"task-api/internal/repository"
+	"time"
 )
 
 type Task interface {
@@ -12,9 +13,50 @@ type Task interface {
 	Type() string
 }
 
+// Параметры создания задачи.
+type CreateParams struct {
+	Retry *repository.RetryPolicy
+	// Ограничение времени одной попытки выполнения.
+	Timeout time.Duration
+	// Крайний срок выполнения задачи.
+	Deadline time.Time
+	// Время запуска отложенной задачи.
+	RunAt time.Time
+	// Расписание, по которому создана задача.
+	ScheduleID uint64
+	// Уже существующие задачи, после успешного выполнения которых запускается задача.
+	DependsOn []uint64
+	// Приоритет в очереди исполнителя: задачи с большим приоритетом выполняются раньше.
+	Priority int
+	// Произвольные метки задачи.
+	Labels map[string]string
+	// Владелец ключа API, с которым создается задача.
+	Owner string
+}
+
+// Узел процесса: задача и индексы узлов, от которых она зависит.
+type WorkflowNode struct {
+	Task      Task
+	Params    CreateParams
+	DependsOn []int
+}
+
+// Создает задачу по типу и параметрам, например factory.Factory.
+type Constructor interface {
+	Construct(taskType string, opts map[string]any) (Task, error)
+}
+
 // Оператор отдает задачи на исполнение и взаимодействует с хранилищем.
 type Operator interface {
-	Create(ctx context.Context, task Task) (*repository.Task, error)
+	Create(ctx context.Context, task Task, params CreateParams) (*repository.Task, error)
+	// Атомарно создает набор связанных задач. Задачи возвращаются в порядке узлов.
+	CreateWorkflow(ctx context.Context, nodes []WorkflowNode) ([]*repository.Task, error)
 	Cancel(ctx context.Context, taskID uint64) (*repository.Task, error)
 	Delete(ctx context.Context, taskID uint64) error
+	QueuePosition(ctx context.Context, taskID uint64) (int, bool)
+	// Квоты одновременно выполняемых задач и их использование.
+	Quotas(ctx context.Context) []executor.Quota
+	// Ждет, пока задача не перейдет в конечный статус, или отмены ctx.
+	// При отмене возвращает текущее состояние задачи и ошибку ctx.
+	Wait(ctx context.Context, taskID uint64) (*repository.Task, error)
 }
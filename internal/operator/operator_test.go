@@ -2,10 +2,12 @@ package operator
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"task-api/internal/executor"
 	"task-api/internal/repository"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -17,12 +19,42 @@ func TestOperatorCreate(t *testing.T) {
 	oper := New(repo, exec)
 	ctx := context.Background()
 
-	task, err := oper.Create(ctx, exectask)
+	task, err := oper.Create(ctx, exectask, CreateParams{Labels: map[string]string{"env": "prod"}})
 	assert.Nil(t, err)
 	assert.Equal(t, task.ID, uint64(1))
 	assert.Equal(t, exec.taskID, uint64(1))
 	assert.Equal(t, exec.task, exectask)
 	assert.Equal(t, task.Options["test"], 42)
+	assert.Equal(t, task.Labels, map[string]string{"env": "prod"})
+}
+
+func TestOperatorCanceledContext(t *testing.T) {
+	repo := repository.New()
+	oper := New(repo, &mockExec{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := oper.Create(ctx, &mockTask{}, CreateParams{})
+	assert.ErrorIs(t, err, context.Canceled)
+	list, _ := repo.List(context.Background(), repository.ListQuery{})
+	assert.Empty(t, list.Tasks)
+	assert.ErrorIs(t, oper.Delete(ctx, 1), context.Canceled)
+}
+
+func TestOperatorHealth(t *testing.T) {
+	exec := &mockExec{results: make(chan executor.TaskResult)}
+	oper := New(repository.New(), exec, WithStallTimeout(time.Second))
+	ctx := context.Background()
+	assert.NoError(t, oper.Health(ctx))
+
+	exec.backlog = 2 * time.Second
+	assert.Error(t, oper.Health(ctx))
+	exec.backlog = 0
+
+	close(exec.results)
+	assert.Eventually(t, func() bool {
+		return oper.Health(ctx) != nil
+	}, time.Second, 5*time.Millisecond)
 }
 
 func TestOperatorCancel(t *testing.T) {
@@ -32,10 +64,10 @@ func TestOperatorCancel(t *testing.T) {
 	oper := New(repo, exec)
 	ctx := context.Background()
 
-	task, _ := oper.Create(ctx, exectask)
+	task, _ := oper.Create(ctx, exectask, CreateParams{})
 	task, err := oper.Cancel(ctx, task.ID)
 	assert.Nil(t, err)
-	assert.Equal(t, task.Aborted, true)
+	assert.Equal(t, task.Status, repository.StatusAborted)
 	assert.Equal(t, exec.canceledTaskID, task.ID)
 }
 
@@ -46,13 +78,381 @@ func TestOperatorDelete(t *testing.T) {
 	oper := New(repo, exec)
 	ctx := context.Background()
 
-	task, _ := oper.Create(ctx, exectask)
+	task, _ := oper.Create(ctx, exectask, CreateParams{})
 	err := oper.Delete(ctx, task.ID)
 	assert.Nil(t, err)
 	assert.Equal(t, exec.canceledTaskID, task.ID)
 	assert.Nil(t, repo.task)
 }
 
+func TestOperatorFailedResult(t *testing.T) {
+	repo := &mockRepo{}
+	exec := &mockExec{results: make(chan executor.TaskResult)}
+	oper := New(repo, exec)
+	ctx := context.Background()
+
+	task, _ := oper.Create(ctx, &mockTask{}, CreateParams{})
+	task, _ = repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusRunning
+		return t, nil
+	})
+	exec.results <- executor.TaskResult{
+		TaskID: task.ID,
+		Error:  executor.NewFailure("custom", fmt.Errorf("error")),
+	}
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusFailed
+	}, time.Second, 5*time.Millisecond)
+	found, _ := repo.Find(ctx, task.ID)
+	assert.Equal(t, found.Error, "error")
+	assert.Equal(t, found.ErrorCode, "custom")
+	assert.Nil(t, found.Result)
+}
+
+func TestOperatorRetry(t *testing.T) {
+	repo := &mockRepo{}
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	ctx := context.Background()
+
+	task, err := oper.Create(ctx, &flakyTask{failures: 2}, CreateParams{
+		Retry: &repository.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+		},
+	})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusExecuted
+	}, time.Second, 5*time.Millisecond)
+	found, _ := repo.Find(ctx, task.ID)
+	assert.Len(t, found.Attempts, 3)
+	assert.Equal(t, found.Attempts[0].Error, "flaky")
+	assert.Empty(t, found.Attempts[2].Error)
+	assert.Equal(t, found.Result, 42)
+
+	task, _ = oper.Create(ctx, &flakyTask{failures: 1}, CreateParams{
+		Retry: &repository.RetryPolicy{
+			MaxAttempts:    3,
+			RetryableCodes: []string{"timeout"},
+		},
+	})
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusFailed
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = oper.Create(ctx, &flakyTask{}, CreateParams{
+		Retry: &repository.RetryPolicy{MaxAttempts: 0},
+	})
+	assert.IsType(t, &Error{}, err)
+}
+
+func TestOperatorTimeout(t *testing.T) {
+	repo := &mockRepo{}
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	ctx := context.Background()
+
+	task, err := oper.Create(ctx, &hangingTask{}, CreateParams{
+		Timeout: 20 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusTimedOut
+	}, time.Second, 5*time.Millisecond)
+	found, _ := repo.Find(ctx, task.ID)
+	assert.Equal(t, found.ErrorCode, executor.FailureCodeTimeout)
+	assert.NotZero(t, found.FinishedAt)
+
+	_, err = oper.Create(ctx, &hangingTask{}, CreateParams{
+		Deadline: time.Now().Add(-time.Second),
+	})
+	assert.IsType(t, &Error{}, err)
+}
+
+func TestOperatorScheduled(t *testing.T) {
+	repo := &mockRepo{}
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	ctx := context.Background()
+
+	task, err := oper.Create(ctx, &flakyTask{}, CreateParams{
+		RunAt: time.Now().Add(50 * time.Millisecond),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, task.Status, repository.StatusScheduled)
+	assert.NotZero(t, task.RunAt)
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusExecuted
+	}, time.Second, 5*time.Millisecond)
+
+	task, _ = oper.Create(ctx, &flakyTask{}, CreateParams{
+		RunAt: time.Now().Add(time.Minute),
+	})
+	task, err = oper.Cancel(ctx, task.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, task.Status, repository.StatusAborted)
+}
+
+func TestOperatorRestoreScheduled(t *testing.T) {
+	repo := repository.New()
+	ctx := context.Background()
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	task, _ := oper.Create(ctx, &flakyTask{}, CreateParams{
+		RunAt: time.Now().Add(time.Minute),
+	})
+	oper.Stop()
+	found, _ := repo.Find(ctx, task.ID)
+	assert.Equal(t, found.Status, repository.StatusScheduled)
+
+	_, err := repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.RunAt = time.Now().Unix()
+		return t, nil
+	})
+	assert.NoError(t, err)
+	New(repo, executor.New(executor.WithWorkers(1)), WithConstructor(&mockConstructor{}))
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusExecuted
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestOperatorDependencies(t *testing.T) {
+	repo := repository.New()
+	exec := &mockExec{results: make(chan executor.TaskResult)}
+	oper := New(repo, exec)
+	ctx := context.Background()
+
+	tasks, err := oper.CreateWorkflow(ctx, []WorkflowNode{
+		{Task: &mockTask{}},
+		{Task: &mockTask{}, DependsOn: []int{0}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, tasks[0].Status, repository.StatusQueued)
+	assert.Equal(t, tasks[1].Status, repository.StatusBlocked)
+	assert.Equal(t, tasks[1].DependsOn, []uint64{tasks[0].ID})
+
+	// Зависимость от существующей задачи.
+	third, err := oper.Create(ctx, &mockTask{}, CreateParams{DependsOn: []uint64{tasks[1].ID}})
+	assert.NoError(t, err)
+	assert.Equal(t, third.Status, repository.StatusBlocked)
+
+	repo.Update(ctx, tasks[0].ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusRunning
+		return t, nil
+	})
+	exec.results <- executor.TaskResult{TaskID: tasks[0].ID, Data: 42}
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, tasks[1].ID)
+		return found.Status == repository.StatusQueued
+	}, time.Second, 5*time.Millisecond)
+	found, _ := repo.Find(ctx, third.ID)
+	assert.Equal(t, found.Status, repository.StatusBlocked)
+
+	_, err = oper.Create(ctx, &mockTask{}, CreateParams{DependsOn: []uint64{100}})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+}
+
+func TestOperatorDependencyFailed(t *testing.T) {
+	repo := repository.New()
+	exec := &mockExec{results: make(chan executor.TaskResult)}
+	oper := New(repo, exec)
+	ctx := context.Background()
+
+	tasks, err := oper.CreateWorkflow(ctx, []WorkflowNode{
+		{Task: &mockTask{}},
+		{Task: &mockTask{}, DependsOn: []int{0}},
+		{Task: &mockTask{}, DependsOn: []int{1}},
+	})
+	assert.NoError(t, err)
+
+	repo.Update(ctx, tasks[0].ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusRunning
+		return t, nil
+	})
+	exec.results <- executor.TaskResult{TaskID: tasks[0].ID, Error: fmt.Errorf("error")}
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, tasks[2].ID)
+		return found.Status == repository.StatusFailed
+	}, time.Second, 5*time.Millisecond)
+	found, _ := repo.Find(ctx, tasks[1].ID)
+	assert.Equal(t, found.Status, repository.StatusFailed)
+	assert.Equal(t, found.ErrorCode, ErrorCodeDependencyFailed)
+
+	// Отмена задачи отменяет зависимые от нее.
+	first, _ := oper.Create(ctx, &mockTask{}, CreateParams{})
+	second, _ := oper.Create(ctx, &mockTask{}, CreateParams{DependsOn: []uint64{first.ID}})
+	_, err = oper.Cancel(ctx, first.ID)
+	assert.NoError(t, err)
+	found, _ = repo.Find(ctx, second.ID)
+	assert.Equal(t, found.Status, repository.StatusAborted)
+
+	// От неуспешной задачи зависеть нельзя.
+	_, err = oper.Create(ctx, &mockTask{}, CreateParams{DependsOn: []uint64{tasks[0].ID}})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+}
+
+func TestOperatorWorkflowCycle(t *testing.T) {
+	repo := repository.New()
+	oper := New(repo, &mockExec{})
+	ctx := context.Background()
+
+	_, err := oper.CreateWorkflow(ctx, []WorkflowNode{
+		{Task: &mockTask{}, DependsOn: []int{2}},
+		{Task: &mockTask{}, DependsOn: []int{0}},
+		{Task: &mockTask{}, DependsOn: []int{1}},
+	})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+	_, err = repo.Find(ctx, 1)
+	assert.Error(t, err)
+}
+
+func TestOperatorProgress(t *testing.T) {
+	repo := repository.New()
+	exec := &mockExec{}
+	oper := New(repo, exec)
+	ctx := context.Background()
+
+	created, err := oper.Create(ctx, &progressTask{}, CreateParams{})
+	assert.NoError(t, err)
+	tracked, _ := oper.tasks.Get(created.ID)
+	_, err = tracked.Execute(ctx)
+	assert.NoError(t, err)
+	found, _ := repo.Find(ctx, created.ID)
+	assert.NotNil(t, found.Progress)
+	assert.Equal(t, found.Progress.Percent, 50.0)
+	assert.Equal(t, found.Progress.Message, "half")
+}
+
+type progressTask struct {
+	mockTask
+}
+
+func (t *progressTask) Execute(ctx context.Context) (any, error) {
+	executor.ReportProgress(ctx, executor.Progress{Percent: 50, Message: "half"})
+	return nil, nil
+}
+
+func TestOperatorWait(t *testing.T) {
+	repo := repository.New()
+	exec := &mockExec{results: make(chan executor.TaskResult)}
+	oper := New(repo, exec)
+	ctx := context.Background()
+
+	task, _ := oper.Create(ctx, &mockTask{}, CreateParams{})
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	found, err := oper.Wait(waitCtx, task.ID)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, found.Status, repository.StatusQueued)
+
+	done := make(chan *repository.Task)
+	go func() {
+		found, _ := oper.Wait(ctx, task.ID)
+		done <- found
+	}()
+	repo.Update(ctx, task.ID, func(t repository.Task) (repository.Task, error) {
+		t.Status = repository.StatusRunning
+		return t, nil
+	})
+	exec.results <- executor.TaskResult{TaskID: task.ID, Data: 42}
+	select {
+	case found := <-done:
+		assert.Equal(t, found.Status, repository.StatusExecuted)
+		assert.Equal(t, found.Result, 42)
+	case <-time.After(time.Second):
+		t.Fatal("ожидание не завершилось")
+	}
+
+	_, err = oper.Wait(ctx, 100)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeNotFound)
+}
+
+type mockConstructor struct{}
+
+// Construct implements Constructor.
+func (c *mockConstructor) Construct(taskType string, opts map[string]any) (Task, error) {
+	return &flakyTask{}, nil
+}
+
+// Задача, которая не реагирует на отмену контекста.
+type hangingTask struct {
+	mockTask
+}
+
+func (t *hangingTask) Execute(context.Context) (any, error) {
+	select {}
+}
+
+func TestOperatorShutdown(t *testing.T) {
+	repo := repository.New()
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	ctx := context.Background()
+
+	running, _ := oper.Create(ctx, &hangingTask{}, CreateParams{})
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, running.ID)
+		return found.Status == repository.StatusRunning
+	}, time.Second, 5*time.Millisecond)
+	queued, _ := oper.Create(ctx, &mockTask{}, CreateParams{})
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, oper.Shutdown(shutdownCtx))
+	for _, id := range []uint64{running.ID, queued.ID} {
+		found, _ := repo.Find(ctx, id)
+		assert.Equal(t, found.Status, repository.StatusInterrupted)
+		assert.Equal(t, found.ErrorCode, repository.ErrorCodeInterrupted)
+	}
+	assert.Error(t, oper.Health(ctx))
+
+	_, err := oper.Create(ctx, &mockTask{}, CreateParams{})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeUnavailable)
+}
+
+func TestOperatorCancelRetrying(t *testing.T) {
+	repo := &mockRepo{}
+	oper := New(repo, executor.New(executor.WithWorkers(1)))
+	ctx := context.Background()
+
+	task, _ := oper.Create(ctx, &flakyTask{failures: 1}, CreateParams{
+		Retry: &repository.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Minute,
+		},
+	})
+	assert.Eventually(t, func() bool {
+		found, _ := repo.Find(ctx, task.ID)
+		return found.Status == repository.StatusRetrying
+	}, time.Second, 5*time.Millisecond)
+	task, err := oper.Cancel(ctx, task.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, task.Status, repository.StatusAborted)
+}
+
+type flakyTask struct {
+	mockTask
+	mu       sync.Mutex
+	failures int
+}
+
+func (t *flakyTask) Execute(context.Context) (any, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures > 0 {
+		t.failures--
+		return nil, fmt.Errorf("flaky")
+	}
+	return 42, nil
+}
+
 type mockTask struct{}
 
 // Execute implements Task.
@@ -94,6 +494,8 @@ func (r *mockRepo) Delete(ctx context.Context, taskID uint64) error {
 
 // Find implements repository.Repository.
 func (r *mockRepo) Find(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	if r.task == nil || r.task.ID != taskID {
 		return nil, repository.NewError(repository.ErrCodeNotFound, "")
 	}
@@ -101,12 +503,14 @@ func (r *mockRepo) Find(ctx context.Context, taskID uint64) (*repository.Task, e
 }
 
 // List implements repository.Repository.
-func (r *mockRepo) List(ctx context.Context) ([]repository.Task, error) {
+func (r *mockRepo) List(ctx context.Context, q repository.ListQuery) (*repository.ListResult, error) {
 	panic("unimplemented")
 }
 
 // Update implements repository.Repository.
 func (r *mockRepo) Update(ctx context.Context, taskID uint64, update func(t repository.Task) (repository.Task, error)) (*repository.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.task == nil || r.task.ID != taskID {
 		return nil, repository.NewError(repository.ErrCodeNotFound, "")
 	}
@@ -118,10 +522,27 @@ func (r *mockRepo) Update(ctx context.Context, taskID uint64, update func(t repo
 	return r.task, nil
 }
 
+// ReserveIdempotencyKey implements repository.Repository.
+func (r *mockRepo) ReserveIdempotencyKey(ctx context.Context, key repository.IdempotencyKey) (*repository.IdempotencyKey, error) {
+	panic("unimplemented")
+}
+
+// SaveIdempotencyKey implements repository.Repository.
+func (r *mockRepo) SaveIdempotencyKey(ctx context.Context, key repository.IdempotencyKey) error {
+	panic("unimplemented")
+}
+
+// DeleteIdempotencyKey implements repository.Repository.
+func (r *mockRepo) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	panic("unimplemented")
+}
+
 type mockExec struct {
+	results        chan executor.TaskResult
 	taskID         uint64
 	task           executor.Task
 	canceledTaskID uint64
+	backlog        time.Duration
 }
 
 // Cancel implements executor.Executor.
@@ -131,14 +552,43 @@ func (e *mockExec) Cancel(ctx context.Context, taskID uint64) error {
 }
 
 // Execute implements executor.Executor.
-func (e *mockExec) Execute(ctx context.Context, taskID uint64, task executor.Task) error {
+func (e *mockExec) Execute(ctx context.Context, taskID uint64, task executor.Task, opts ...executor.ExecuteOption) error {
 	e.taskID = taskID
+	if tracked, ok := task.(*trackedTask); ok {
+		task = tracked.Task
+	}
 	e.task = task
 	return nil
 }
 
+// ResultsBacklog implements executor.Executor.
+func (e *mockExec) ResultsBacklog(ctx context.Context) (int, time.Duration) {
+	if e.backlog > 0 {
+		return 1, e.backlog
+	}
+	return 0, 0
+}
+
+// Shutdown implements executor.Executor.
+func (e *mockExec) Shutdown(ctx context.Context) {
+	close(e.results)
+}
+
+// Quotas implements executor.Executor.
+func (e *mockExec) Quotas(ctx context.Context, namespace string) []executor.Quota {
+	return nil
+}
+
+// QueuePosition implements executor.Executor.
+func (e *mockExec) QueuePosition(ctx context.Context, taskID uint64) (int, bool) {
+	return 0, false
+}
+
 // Results implements executor.Executor.
 func (e *mockExec) Results(ctx context.Context) <-chan executor.TaskResult {
+	if e.results != nil {
+		return e.results
+	}
 	ch := make(<-chan executor.TaskResult)
 	return ch
 }
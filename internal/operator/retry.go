@@ -0,0 +1,59 @@
+package operator
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"task-api/internal/repository"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMultiplier = 2
+	maxRetryBackoff        = time.Hour
+)
+
+// Проверяет политику повторов и заполняет значения по умолчанию.
+func normalizeRetry(p *repository.RetryPolicy) (*repository.RetryPolicy, error) {
+	if p == nil {
+		return nil, nil
+	}
+	policy := *p
+	if policy.MaxAttempts < 1 {
+		return nil, NewError(ErrCodeBadInput, "`max_attempts` политики повторов должен быть >= 1")
+	}
+	if policy.InitialBackoff < 0 {
+		return nil, NewError(ErrCodeBadInput, "задержка перед повтором не может быть отрицательной")
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return nil, NewError(ErrCodeBadInput, "`multiplier` политики повторов должен быть >= 1")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return nil, NewError(ErrCodeBadInput, "`jitter` политики повторов должен быть в диапазоне [0, 1]")
+	}
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = defaultRetryBackoff
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = defaultRetryMultiplier
+	}
+	return &policy, nil
+}
+
+// Нужен ли повтор после attempts неудачных попыток с ошибкой code.
+func shouldRetry(p *repository.RetryPolicy, code string, attempts int) bool {
+	if p == nil || attempts >= p.MaxAttempts {
+		return false
+	}
+	return len(p.RetryableCodes) == 0 || slices.Contains(p.RetryableCodes, code)
+}
+
+// Задержка перед попыткой attempts+1: initial * multiplier^(attempts-1) ± jitter.
+func retryBackoff(p repository.RetryPolicy, attempts int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempts-1))
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(min(d, float64(maxRetryBackoff)))
+}
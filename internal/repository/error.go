@@ -4,6 +4,7 @@ type ErrCode int
 
 const (
 	ErrCodeNotFound ErrCode = iota
+	ErrCodeBadInput
 )
 
 type Error struct {
@@ -0,0 +1,411 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"task-api/pkg/timing"
+	"time"
+)
+
+const (
+	snapshotFileName     = "snapshot.json"
+	logFileName          = "tasks.log"
+	defaultSnapshotEvery = 1000
+)
+
+type logOp string
+
+const (
+	logOpPut       logOp = "put"
+	logOpDelete    logOp = "delete"
+	logOpKeyPut    logOp = "key_put"
+	logOpKeyDelete logOp = "key_delete"
+)
+
+// Запись журнала. put хранит полное состояние задачи,
+// поэтому повторное применение записи безопасно.
+type logRecord struct {
+	Op   logOp           `json:"op"`
+	Task *Task           `json:"task,omitempty"`
+	ID   uint64          `json:"id,omitempty"`
+	Key  *IdempotencyKey `json:"key,omitempty"`
+}
+
+type snapshot struct {
+	NextID uint64           `json:"next_id"`
+	Tasks  []Task           `json:"tasks"`
+	Keys   []IdempotencyKey `json:"keys,omitempty"`
+}
+
+var _ Repository = (*fileRepository)(nil)
+
+// Хранилище задач на диске: журнал изменений (append-only) и периодические
+// снимки состояния. Чтение обслуживается из памяти.
+type fileRepository struct {
+	mem           *repository
+	dir           string
+	snapshotEvery int
+
+	mu      sync.Mutex
+	log     logFile
+	entries int
+	// Ошибка, после которой журнал нельзя дописывать: его конец не удалось восстановить.
+	broken error
+}
+
+// Файл журнала. *os.File, в тестах - обертка, имитирующая ошибки записи.
+type logFile interface {
+	io.WriteCloser
+	io.Seeker
+	Sync() error
+	Truncate(size int64) error
+}
+
+type FileOption func(r *fileRepository)
+
+// Число записей журнала, после которого делается снимок и журнал обнуляется.
+func WithSnapshotEvery(n int) FileOption {
+	return func(r *fileRepository) {
+		r.snapshotEvery = n
+	}
+}
+
+// Открывает хранилище в каталоге dir, восстанавливая состояние из снимка
+// и журнала. Задачи, которые ожидали или выполнялись в момент остановки,
+// помечаются прерванными.
+func NewFile(dir string, opts ...FileOption) (*fileRepository, error) {
+	r := &fileRepository{
+		mem:           New(),
+		dir:           dir,
+		snapshotEvery: defaultSnapshotEvery,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := r.replayLog(); err != nil {
+		return nil, err
+	}
+	r.markInterrupted()
+	if err := r.writeSnapshot(); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(r.path(logFileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	r.log = log
+	return r, nil
+}
+
+// Create implements Repository.
+func (r *fileRepository) Create(ctx context.Context, task Task) (*Task, error) {
+	if ns, ok := NamespaceFromContext(ctx); ok {
+		task.Namespace = ns
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mem.mu.RLock()
+	task.ID = r.mem.currentTaskID
+	r.mem.mu.RUnlock()
+	if err := r.commit(logRecord{Op: logOpPut, Task: &task}); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Find implements Repository.
+func (r *fileRepository) Find(ctx context.Context, taskID uint64) (*Task, error) {
+	return r.mem.Find(ctx, taskID)
+}
+
+// List implements Repository.
+func (r *fileRepository) List(ctx context.Context, q ListQuery) (*ListResult, error) {
+	return r.mem.List(ctx, q)
+}
+
+// Update implements Repository.
+func (r *fileRepository) Update(ctx context.Context, taskID uint64, update func(t Task) (Task, error)) (*Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Память изменяется только под r.mu, поэтому задача не изменится до фиксации.
+	task, err := r.mem.Find(context.WithoutCancel(ctx), taskID)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := update(*task)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.commit(logRecord{Op: logOpPut, Task: &updated}); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete implements Repository.
+func (r *fileRepository) Delete(ctx context.Context, taskID uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mem.mu.RLock()
+	task, ok := r.mem.store[taskID]
+	r.mem.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	if !visible(ctx, task) {
+		msg := fmt.Sprintf("задача с id %d не найдена", taskID)
+		return NewError(ErrCodeNotFound, msg)
+	}
+	return r.commit(logRecord{Op: logOpDelete, ID: taskID})
+}
+
+// ReserveIdempotencyKey implements Repository.
+func (r *fileRepository) ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey) (*IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mem.mu.Lock()
+	existing := r.mem.activeKey(key.Key)
+	r.mem.mu.Unlock()
+	if existing != nil {
+		return existing, nil
+	}
+	return nil, r.commit(logRecord{Op: logOpKeyPut, Key: &key})
+}
+
+// SaveIdempotencyKey implements Repository.
+func (r *fileRepository) SaveIdempotencyKey(ctx context.Context, key IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commit(logRecord{Op: logOpKeyPut, Key: &key})
+}
+
+// DeleteIdempotencyKey implements Repository.
+func (r *fileRepository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commit(logRecord{Op: logOpKeyDelete, Key: &IdempotencyKey{Key: key}})
+}
+
+// Делает финальный снимок и закрывает журнал.
+func (r *fileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.writeSnapshot(); err != nil {
+		return err
+	}
+	return r.log.Close()
+}
+
+func (r *fileRepository) path(name string) string {
+	return filepath.Join(r.dir, name)
+}
+
+// Записывает изменение в журнал и только после успешной записи применяет его
+// в памяти: изменение, не попавшее в журнал, не попадет и в следующий снимок.
+// Вызывается под r.mu.
+func (r *fileRepository) commit(rec logRecord) error {
+	if err := r.append(rec); err != nil {
+		return err
+	}
+	r.mem.apply(rec)
+	r.entries++
+	if r.snapshotEvery > 0 && r.entries >= r.snapshotEvery {
+		// Изменение уже в журнале, поэтому ошибка снимка его не отменяет:
+		// снимок повторится при следующей записи.
+		if err := r.compact(); err != nil {
+			slog.Error(fmt.Sprintf("хранилище: не удалось сделать снимок: %s", err))
+		}
+	}
+	return nil
+}
+
+// Дописывает запись в журнал. Если запись не удалась, журнал обрезается до прежнего
+// конца, чтобы недописанная строка не склеилась со следующей записью.
+func (r *fileRepository) append(rec logRecord) error {
+	if r.broken != nil {
+		return r.broken
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	end, err := r.log.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = r.log.Write(line); err == nil {
+		err = r.log.Sync()
+	}
+	if err != nil {
+		if truncErr := r.log.Truncate(end); truncErr != nil {
+			r.broken = fmt.Errorf("журнал хранилища поврежден, запись невозможна: %w", truncErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Делает снимок и обнуляет журнал.
+func (r *fileRepository) compact() error {
+	if err := r.writeSnapshot(); err != nil {
+		return err
+	}
+	return r.log.Truncate(0)
+}
+
+// Атомарно записывает снимок: во временный файл, затем rename.
+func (r *fileRepository) writeSnapshot() error {
+	r.mem.mu.RLock()
+	snap := snapshot{
+		NextID: r.mem.currentTaskID,
+		Tasks:  make([]Task, 0, len(r.mem.store)),
+	}
+	for _, task := range r.mem.store {
+		snap.Tasks = append(snap.Tasks, task)
+	}
+	now := time.Now().Unix()
+	for _, key := range r.mem.keys {
+		if !key.expired(now) {
+			snap.Keys = append(snap.Keys, key)
+		}
+	}
+	r.mem.mu.RUnlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp := r.path(snapshotFileName + ".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path(snapshotFileName)); err != nil {
+		return err
+	}
+	r.entries = 0
+	return nil
+}
+
+func (r *fileRepository) loadSnapshot() error {
+	data, err := os.ReadFile(r.path(snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("снимок хранилища поврежден: %w", err)
+	}
+	for _, task := range snap.Tasks {
+		r.mem.store[task.ID] = task
+	}
+	for _, key := range snap.Keys {
+		r.mem.keys[key.Key] = key
+	}
+	r.mem.currentTaskID = max(r.mem.currentTaskID, snap.NextID)
+	return nil
+}
+
+// Применяет журнал поверх снимка. Недописанная при аварии запись
+// в конце журнала отбрасывается, поврежденная запись в середине - ошибка.
+func (r *fileRepository) replayLog() error {
+	f, err := os.Open(r.path(logFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				slog.Warn(fmt.Sprintf("журнал хранилища: недописанная запись %d отброшена", n))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec logRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			// Поврежденная запись в середине журнала - не след аварии: восстановление
+			// без нее потеряло бы все следующие записи, поэтому журнал не трогаем.
+			rest, readErr := io.ReadAll(reader)
+			if readErr != nil {
+				return readErr
+			}
+			if len(bytes.TrimSpace(rest)) > 0 {
+				return fmt.Errorf("журнал хранилища поврежден: запись %d: %w", n, err)
+			}
+			slog.Warn(fmt.Sprintf("журнал хранилища: недописанная запись %d отброшена", n))
+			return nil
+		}
+		r.mem.apply(rec)
+	}
+}
+
+// Применяет запись журнала к состоянию в памяти.
+func (r *repository) apply(rec logRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch rec.Op {
+	case logOpPut:
+		if rec.Task != nil {
+			r.store[rec.Task.ID] = *rec.Task
+			r.currentTaskID = max(r.currentTaskID, rec.Task.ID+1)
+		}
+	case logOpDelete:
+		delete(r.store, rec.ID)
+	case logOpKeyPut:
+		if rec.Key != nil {
+			r.keys[rec.Key.Key] = *rec.Key
+		}
+	case logOpKeyDelete:
+		if rec.Key != nil {
+			delete(r.keys, rec.Key.Key)
+		}
+	}
+}
+
+func (r *fileRepository) markInterrupted() {
+	now := timing.Timestamp()
+	for id, task := range r.mem.store {
+		if task.Status.Interruptible() {
+			task.Interrupt(now)
+			r.mem.store[id] = task
+		}
+	}
+}
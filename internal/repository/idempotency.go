@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Ключ идемпотентности: связывает повторы запроса с задачей, созданной первым из них.
+type IdempotencyKey struct {
+	Key string `json:"key"`
+	// Отпечаток параметров запроса: повтор с другими параметрами отклоняется.
+	Fingerprint string `json:"fingerprint"`
+	// Задача, созданная по ключу. 0 - запрос еще выполняется.
+	TaskID    uint64 `json:"task_id,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func (k IdempotencyKey) expired(now int64) bool {
+	return k.ExpiresAt <= now
+}
+
+// Как часто удаляются просроченные ключи.
+const keysSweepInterval = time.Minute
+
+// ReserveIdempotencyKey implements Repository.
+func (r *repository) ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey) (*IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.activeKey(key.Key); existing != nil {
+		return existing, nil
+	}
+	r.keys[key.Key] = key
+	return nil, nil
+}
+
+// Непросроченный ключ key, nil - ключа нет. Вызывается под блокировкой.
+func (r *repository) activeKey(key string) *IdempotencyKey {
+	now := time.Now().Unix()
+	r.sweepKeys(now)
+	if existing, ok := r.keys[key]; ok && !existing.expired(now) {
+		return &existing
+	}
+	return nil
+}
+
+// SaveIdempotencyKey implements Repository.
+func (r *repository) SaveIdempotencyKey(ctx context.Context, key IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[key.Key] = key
+	return nil
+}
+
+// DeleteIdempotencyKey implements Repository.
+func (r *repository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, key)
+	return nil
+}
+
+// Удаляет просроченные ключи не чаще keysSweepInterval. Вызывается под блокировкой.
+func (r *repository) sweepKeys(now int64) {
+	if now-r.keysSweptAt < int64(keysSweepInterval/time.Second) {
+		return
+	}
+	r.keysSweptAt = now
+	for k, key := range r.keys {
+		if key.expired(now) {
+			delete(r.keys, k)
+		}
+	}
+}
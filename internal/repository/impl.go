@@ -3,19 +3,111 @@ package repository
 import (
 	"context"
 	"fmt"
-	"slices"
 	"sync"
+	"time"
 )
 
+type Status string
+
+const (
+	StatusQueued      Status = "queued"
+	StatusRunning     Status = "running"
+	StatusExecuted    Status = "executed"
+	StatusAborted     Status = "aborted"
+	StatusFailed      Status = "failed"
+	StatusInterrupted Status = "interrupted"
+	StatusRetrying    Status = "retrying"
+	StatusTimedOut    Status = "timed_out"
+	StatusScheduled   Status = "scheduled"
+	// Задача ожидает завершения зависимостей.
+	StatusBlocked Status = "blocked"
+)
+
+// Задача в конечном статусе больше не изменится.
+func (s Status) Terminal() bool {
+	switch s {
+	case StatusExecuted, StatusAborted, StatusFailed, StatusInterrupted, StatusTimedOut:
+		return true
+	}
+	return false
+}
+
+// Код ошибки задачи, прерванной остановкой сервиса.
+const ErrorCodeInterrupted = "interrupted"
+
+// Незавершенная задача, выполнение которой прерывает остановка сервиса.
+// Отложенные задачи (scheduled) переживают перезапуск.
+func (s Status) Interruptible() bool {
+	switch s {
+	case StatusQueued, StatusRunning, StatusRetrying, StatusBlocked:
+		return true
+	}
+	return false
+}
+
+// Переводит задачу в статус interrupted.
+func (t *Task) Interrupt(now int64) {
+	t.Status = StatusInterrupted
+	t.FinishedAt = now
+	t.NextAttemptAt = 0
+	t.Error = "выполнение задачи прервано остановкой сервиса"
+	t.ErrorCode = ErrorCodeInterrupted
+}
+
+// Политика повторного выполнения задачи после ошибки.
+type RetryPolicy struct {
+	MaxAttempts    int           `json:"max_attempts"`
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	Multiplier     float64       `json:"multiplier"`
+	// Доля случайного отклонения задержки, от 0 до 1.
+	Jitter float64 `json:"jitter"`
+	// Коды ошибок, после которых задача повторяется. Пусто - после любых.
+	RetryableCodes []string `json:"retryable_codes,omitempty"`
+}
+
+// Попытка выполнения задачи.
+type Attempt struct {
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"error_code,omitempty"`
+}
+
+// Прогресс выполнения задачи, о котором она сообщила последним.
+type Progress struct {
+	Percent   float64 `json:"percent"`
+	Step      string  `json:"step,omitempty"`
+	Message   string  `json:"message,omitempty"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
 type Task struct {
-	ID         uint64
-	CreatedAt  int64
-	FinishedAt int64
-	Type       string
-	Options    map[string]any
-	Aborted    bool
-	Error      string
-	Result     any
+	ID         uint64         `json:"id"`
+	Status     Status         `json:"status"`
+	CreatedAt  int64          `json:"created_at"`
+	StartedAt  int64          `json:"started_at,omitempty"`
+	FinishedAt int64          `json:"finished_at,omitempty"`
+	Type       string         `json:"type"`
+	Options    map[string]any `json:"options,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	ErrorCode  string         `json:"error_code,omitempty"`
+	Result     any            `json:"result,omitempty"`
+
+	Timeout       time.Duration     `json:"timeout,omitempty"`
+	Deadline      int64             `json:"deadline,omitempty"`
+	RunAt         int64             `json:"run_at,omitempty"`
+	ScheduleID    uint64            `json:"schedule_id,omitempty"`
+	Retry         *RetryPolicy      `json:"retry,omitempty"`
+	Attempts      []Attempt         `json:"attempts,omitempty"`
+	NextAttemptAt int64             `json:"next_attempt_at,omitempty"`
+	DependsOn     []uint64          `json:"depends_on,omitempty"`
+	Priority      int               `json:"priority,omitempty"`
+	Progress      *Progress         `json:"progress,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	// Владелец ключа API, с которым создана задача.
+	Owner string `json:"owner,omitempty"`
+	// Пространство имен задачи.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 var _ Repository = (*repository)(nil)
@@ -24,17 +116,23 @@ type repository struct {
 	mu            sync.RWMutex
 	currentTaskID uint64
 	store         map[uint64]Task
+	keys          map[string]IdempotencyKey
+	keysSweptAt   int64
 }
 
 func New() *repository {
 	return &repository{
 		currentTaskID: 1,
 		store:         make(map[uint64]Task),
+		keys:          make(map[string]IdempotencyKey),
 	}
 }
 
 // Create implements Repository.
 func (r *repository) Create(ctx context.Context, task Task) (*Task, error) {
+	if ns, ok := NamespaceFromContext(ctx); ok {
+		task.Namespace = ns
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	task.ID = r.currentTaskID
@@ -45,10 +143,13 @@ func (r *repository) Create(ctx context.Context, task Task) (*Task, error) {
 
 // Find implements Repository.
 func (r *repository) Find(ctx context.Context, taskID uint64) (*Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	task, ok := r.store[taskID]
-	if !ok {
+	if !ok || !visible(ctx, task) {
 		msg := fmt.Sprintf("задача с id %d не найдена", taskID)
 		return nil, NewError(ErrCodeNotFound, msg)
 	}
@@ -56,15 +157,19 @@ func (r *repository) Find(ctx context.Context, taskID uint64) (*Task, error) {
 }
 
 // List implements Repository.
-func (r *repository) List(context.Context) ([]Task, error) {
+func (r *repository) List(ctx context.Context, q ListQuery) (*ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
-	defer r.mu.RUnlock()
 	tasks := make([]Task, 0, len(r.store))
 	for _, task := range r.store {
-		tasks = append(tasks, task)
+		if visible(ctx, task) {
+			tasks = append(tasks, task)
+		}
 	}
-	slices.SortFunc(tasks, func(a, b Task) int { return int(a.ID - b.ID) })
-	return tasks, nil
+	r.mu.RUnlock()
+	return selectTasks(tasks, q)
 }
 
 // Update implements Repository.
@@ -72,7 +177,7 @@ func (r *repository) Update(ctx context.Context, taskID uint64, update func(t Ta
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	task, ok := r.store[taskID]
-	if !ok {
+	if !ok || !visible(ctx, task) {
 		msg := fmt.Sprintf("задача с id %d не найдена", taskID)
 		return nil, NewError(ErrCodeNotFound, msg)
 	}
@@ -88,6 +193,10 @@ func (r *repository) Update(ctx context.Context, taskID uint64, update func(t Ta
 func (r *repository) Delete(ctx context.Context, taskID uint64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if task, ok := r.store[taskID]; ok && !visible(ctx, task) {
+		msg := fmt.Sprintf("задача с id %d не найдена", taskID)
+		return NewError(ErrCodeNotFound, msg)
+	}
 	delete(r.store, taskID)
 	return nil
 }
@@ -4,9 +4,16 @@ import "context"
 
 // Хранилище задач.
 type Repository interface {
-	List(ctx context.Context) ([]Task, error)
+	// Выборка задач с фильтрами, сортировкой и постраничной выдачей.
+	List(ctx context.Context, q ListQuery) (*ListResult, error)
 	Find(ctx context.Context, taskID uint64) (*Task, error)
 	Create(ctx context.Context, task Task) (*Task, error)
 	Delete(ctx context.Context, taskID uint64) error
 	Update(ctx context.Context, taskID uint64, update func(t Task) (Task, error)) (*Task, error)
+
+	// Занимает ключ идемпотентности, если он свободен или просрочен, и возвращает nil.
+	// Иначе возвращает действующую запись ключа, не изменяя ее.
+	ReserveIdempotencyKey(ctx context.Context, key IdempotencyKey) (*IdempotencyKey, error)
+	SaveIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
 }
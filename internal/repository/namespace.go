@@ -0,0 +1,39 @@
+package repository
+
+import "context"
+
+// Пространство имен задач, созданных без явного пространства.
+const DefaultNamespace = "default"
+
+type namespaceKey struct{}
+
+// Ограничивает операции хранилища с контекстом ctx задачами пространства имен ns:
+// задачи других пространств для них не существуют, новые задачи создаются в ns.
+func WithNamespace(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, ns)
+}
+
+// Снимает ограничение пространством имен, например для администратора.
+func WithAllNamespaces(ctx context.Context) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, "")
+}
+
+// Пространство имен, которым ограничен контекст. false - ограничения нет.
+func NamespaceFromContext(ctx context.Context) (string, bool) {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns, ns != ""
+}
+
+// Пространство имен задачи. Задачи, сохраненные до появления пространств, относятся к DefaultNamespace.
+func (t Task) NamespaceOrDefault() string {
+	if t.Namespace == "" {
+		return DefaultNamespace
+	}
+	return t.Namespace
+}
+
+// Видна ли задача операциям с контекстом ctx.
+func visible(ctx context.Context, t Task) bool {
+	ns, ok := NamespaceFromContext(ctx)
+	return !ok || t.NamespaceOrDefault() == ns
+}
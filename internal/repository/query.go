@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"task-api/pkg/labels"
+)
+
+type SortField string
+
+const (
+	// По времени создания (по id).
+	SortByCreated SortField = "created"
+	// По приоритету.
+	SortByPriority SortField = "priority"
+)
+
+// Параметры выборки задач. Нулевые значения полей не ограничивают выборку.
+type ListQuery struct {
+	Statuses []Status
+	Types    []string
+	// Время создания: CreatedFrom включительно, CreatedTo не включительно.
+	CreatedFrom int64
+	CreatedTo   int64
+	// Селектор меток задачи.
+	Selector   labels.Selector
+	ScheduleID uint64
+	Owner      string
+	// Пространство имен. Контекст, ограниченный пространством, ограничивает и выборку.
+	Namespace string
+
+	SortBy SortField
+	Desc   bool
+	// Максимальное число задач на странице. 0 - без ограничения.
+	Limit int
+	// Курсор следующей страницы из ListResult.NextCursor.
+	Cursor string
+}
+
+type ListResult struct {
+	Tasks []Task
+	// Курсор следующей страницы. Пусто - страница последняя.
+	NextCursor string
+}
+
+// Позиция в выборке: ключ сортировки и id последней отданной задачи.
+type cursor struct {
+	SortBy SortField `json:"s"`
+	Desc   bool      `json:"d,omitempty"`
+	Key    int64     `json:"k"`
+	ID     uint64    `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, q ListQuery) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	var c cursor
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, NewError(ErrCodeBadInput, fmt.Sprintf("неверный курсор `%s`", s))
+	}
+	if c.SortBy != q.sortBy() || c.Desc != q.Desc {
+		return nil, NewError(ErrCodeBadInput, "курсор получен для другой сортировки")
+	}
+	return &c, nil
+}
+
+func (q ListQuery) sortBy() SortField {
+	if q.SortBy == "" {
+		return SortByCreated
+	}
+	return q.SortBy
+}
+
+func (q ListQuery) sortKey(t Task) int64 {
+	if q.sortBy() == SortByPriority {
+		return int64(t.Priority)
+	}
+	return int64(t.ID)
+}
+
+// Порядок задач в выборке. Задачи с равным ключом упорядочены по возрастанию id.
+func (q ListQuery) compare(aKey int64, aID uint64, bKey int64, bID uint64) int {
+	c := cmpInt(aKey, bKey)
+	if q.Desc {
+		c = -c
+	}
+	if c == 0 {
+		c = cmpInt(aID, bID)
+	}
+	return c
+}
+
+func (q ListQuery) match(t Task) bool {
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, t.Status) {
+		return false
+	}
+	if len(q.Types) > 0 && !slices.Contains(q.Types, t.Type) {
+		return false
+	}
+	if q.CreatedFrom != 0 && t.CreatedAt < q.CreatedFrom {
+		return false
+	}
+	if q.CreatedTo != 0 && t.CreatedAt >= q.CreatedTo {
+		return false
+	}
+	if q.ScheduleID != 0 && t.ScheduleID != q.ScheduleID {
+		return false
+	}
+	if q.Owner != "" && t.Owner != q.Owner {
+		return false
+	}
+	if q.Namespace != "" && t.NamespaceOrDefault() != q.Namespace {
+		return false
+	}
+	return q.Selector.Matches(t.Labels)
+}
+
+// Отбирает, сортирует и постранично выдает задачи.
+// Используется хранилищами без собственных индексов.
+func selectTasks(tasks []Task, q ListQuery) (*ListResult, error) {
+	var after *cursor
+	if q.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(q.Cursor, q); err != nil {
+			return nil, err
+		}
+	}
+	if q.Limit < 0 {
+		return nil, NewError(ErrCodeBadInput, "размер страницы не может быть отрицательным")
+	}
+	selected := make([]Task, 0)
+	for _, t := range tasks {
+		if !q.match(t) {
+			continue
+		}
+		if after != nil && q.compare(q.sortKey(t), t.ID, after.Key, after.ID) <= 0 {
+			continue
+		}
+		selected = append(selected, t)
+	}
+	slices.SortFunc(selected, func(a, b Task) int {
+		return q.compare(q.sortKey(a), a.ID, q.sortKey(b), b.ID)
+	})
+	res := &ListResult{Tasks: selected}
+	if q.Limit > 0 && len(selected) > q.Limit {
+		res.Tasks = selected[:q.Limit]
+		last := res.Tasks[q.Limit-1]
+		res.NextCursor = encodeCursor(cursor{
+			SortBy: q.sortBy(),
+			Desc:   q.Desc,
+			Key:    q.sortKey(last),
+			ID:     last.ID,
+		})
+	}
+	return res, nil
+}
+
+func cmpInt[T int64 | uint64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -1,8 +1,15 @@
 package repository
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"task-api/pkg/labels"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,9 +58,58 @@ func TestRepositoryList(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, second.ID, uint64(2))
 
-	tasks, err := repo.List(ctx)
+	list, err := repo.List(ctx, ListQuery{})
 	assert.NoError(t, err)
-	assert.Equal(t, len(tasks), 2)
+	assert.Equal(t, len(list.Tasks), 2)
+}
+
+func TestRepositoryListQuery(t *testing.T) {
+	repo := New()
+	ctx := context.Background()
+	for i := range 5 {
+		repo.Create(ctx, Task{
+			Type:      []string{"a", "b"}[i%2],
+			Status:    StatusQueued,
+			CreatedAt: int64(100 + i),
+			Priority:  i % 3,
+			Labels:    map[string]string{"n": []string{"even", "odd"}[i%2]},
+		})
+	}
+
+	list, err := repo.List(ctx, ListQuery{Types: []string{"a"}, CreatedFrom: 101})
+	assert.NoError(t, err)
+	assert.Len(t, list.Tasks, 2)
+	assert.Equal(t, list.Tasks[0].ID, uint64(3))
+
+	list, _ = repo.List(ctx, ListQuery{Selector: labels.FromMap(map[string]string{"n": "odd"})})
+	assert.Len(t, list.Tasks, 2)
+
+	// Постраничная выдача по убыванию приоритета: 3, 2, 5 | 1, 4.
+	q := ListQuery{SortBy: SortByPriority, Desc: true, Limit: 3}
+	list, err = repo.List(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, ids(list.Tasks), []uint64{3, 2, 5})
+	assert.NotEmpty(t, list.NextCursor)
+	q.Cursor = list.NextCursor
+	list, err = repo.List(ctx, q)
+	assert.NoError(t, err)
+	assert.Equal(t, ids(list.Tasks), []uint64{1, 4})
+	assert.Empty(t, list.NextCursor)
+
+	// Курсор другой сортировки отклоняется.
+	_, err = repo.List(ctx, ListQuery{Cursor: q.Cursor})
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+	_, err = repo.List(ctx, ListQuery{Cursor: "???"})
+	assert.Error(t, err)
+}
+
+func ids(tasks []Task) []uint64 {
+	ids := make([]uint64, 0, len(tasks))
+	for _, t := range tasks {
+		ids = append(ids, t.ID)
+	}
+	return ids
 }
 
 func TestRepositoryDelete(t *testing.T) {
@@ -84,13 +140,230 @@ func TestRepositoryUpdate(t *testing.T) {
 	assert.Equal(t, created.ID, uint64(1))
 
 	updated, err := repo.Update(ctx, created.ID, func(t Task) (Task, error) {
-		t.Aborted = true
+		t.Status = StatusAborted
 		return t, nil
 	})
 	assert.NoError(t, err)
-	assert.True(t, updated.Aborted)
+	assert.Equal(t, updated.Status, StatusAborted)
 
 	updated, err = repo.Find(ctx, updated.ID)
 	assert.NoError(t, err)
-	assert.True(t, updated.Aborted)
+	assert.Equal(t, updated.Status, StatusAborted)
+}
+
+func TestRepositoryNamespace(t *testing.T) {
+	repo := New()
+	teamA := WithNamespace(context.Background(), "team-a")
+	teamB := WithNamespace(context.Background(), "team-b")
+
+	created, err := repo.Create(teamA, Task{})
+	assert.NoError(t, err)
+	assert.Equal(t, created.Namespace, "team-a")
+	_, err = repo.Create(context.Background(), Task{})
+	assert.NoError(t, err)
+
+	// Задачи другого пространства для запроса не существуют.
+	_, err = repo.Find(teamB, created.ID)
+	assert.Error(t, err)
+	_, err = repo.Update(teamB, created.ID, func(t Task) (Task, error) {
+		t.Status = StatusAborted
+		return t, nil
+	})
+	assert.Error(t, err)
+	assert.Error(t, repo.Delete(teamB, created.ID))
+	_, err = repo.Find(teamA, created.ID)
+	assert.NoError(t, err)
+
+	list, err := repo.List(teamB, ListQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list.Tasks), 0)
+	list, err = repo.List(WithNamespace(context.Background(), DefaultNamespace), ListQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list.Tasks), 1)
+	list, err = repo.List(WithAllNamespaces(context.Background()), ListQuery{Namespace: "team-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list.Tasks), 1)
+	assert.Equal(t, list.Tasks[0].ID, created.ID)
+}
+
+func TestFileRepositoryReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	repo, err := NewFile(dir, WithSnapshotEvery(3))
+	assert.NoError(t, err)
+
+	for range 4 {
+		_, err := repo.Create(ctx, Task{Status: StatusExecuted, Type: "test", Result: "ok"})
+		assert.NoError(t, err)
+	}
+	_, err = repo.Update(ctx, 2, func(t Task) (Task, error) {
+		t.Status = StatusAborted
+		return t, nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Delete(ctx, 3))
+
+	// Без Close: имитация аварийной остановки.
+	reopened, err := NewFile(dir)
+	assert.NoError(t, err)
+	list, err := reopened.List(ctx, ListQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Tasks, 3)
+	found, err := reopened.Find(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, found.Status, StatusAborted)
+	assert.Equal(t, found.Result, "ok")
+
+	created, err := reopened.Create(ctx, Task{})
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, uint64(5))
+	assert.NoError(t, reopened.Close())
+}
+
+func TestFileRepositoryRecovery(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	repo, err := NewFile(dir)
+	assert.NoError(t, err)
+	_, err = repo.Create(ctx, Task{Status: StatusRunning})
+	assert.NoError(t, err)
+	_, err = repo.Create(ctx, Task{Status: StatusQueued})
+	assert.NoError(t, err)
+	_, err = repo.Create(ctx, Task{Status: StatusExecuted})
+	assert.NoError(t, err)
+
+	// Недописанная запись в конце журнала.
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"put","task":{"id":4`)
+	assert.NoError(t, err)
+	f.Close()
+
+	reopened, err := NewFile(dir)
+	assert.NoError(t, err)
+	list, err := reopened.List(ctx, ListQuery{})
+	assert.NoError(t, err)
+	tasks := list.Tasks
+	assert.Len(t, tasks, 3)
+	assert.Equal(t, tasks[0].Status, StatusInterrupted)
+	assert.Equal(t, tasks[0].ErrorCode, ErrorCodeInterrupted)
+	assert.NotZero(t, tasks[0].FinishedAt)
+	assert.Equal(t, tasks[1].Status, StatusInterrupted)
+	assert.Equal(t, tasks[2].Status, StatusExecuted)
+}
+
+func TestFileRepositoryCorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	repo, err := NewFile(dir)
+	assert.NoError(t, err)
+	for range 3 {
+		_, err = repo.Create(ctx, Task{Status: StatusExecuted})
+		assert.NoError(t, err)
+	}
+
+	// Поврежденная запись в середине журнала: запуск отклоняется, журнал не изменяется.
+	path := filepath.Join(dir, logFileName)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	corrupt := slices.Concat(lines[0], []byte("{\"op\":\"put\",\n"), lines[2])
+	assert.NoError(t, os.WriteFile(path, corrupt, 0o644))
+	_, err = NewFile(dir)
+	assert.Error(t, err)
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, after, corrupt)
+}
+
+// Файл журнала, запись в который обрывается на середине строки.
+type tornLog struct {
+	*os.File
+	fail bool
+}
+
+func (f *tornLog) Write(p []byte) (int, error) {
+	if f.fail {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errors.New("нет места на диске")
+	}
+	return f.File.Write(p)
+}
+
+func TestFileRepositoryTornAppend(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	repo, err := NewFile(dir)
+	assert.NoError(t, err)
+	log := &tornLog{File: repo.log.(*os.File), fail: true}
+	repo.log = log
+
+	_, err = repo.Create(ctx, Task{Status: StatusExecuted})
+	assert.Error(t, err)
+	log.fail = false
+	created, err := repo.Create(ctx, Task{Status: StatusExecuted})
+	assert.NoError(t, err)
+
+	// Без Close: следующая запись не склеилась с недописанной.
+	reopened, err := NewFile(dir)
+	assert.NoError(t, err)
+	found, err := reopened.Find(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, found.Status, StatusExecuted)
+}
+
+func TestFileRepositoryWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	repo, err := NewFile(t.TempDir())
+	assert.NoError(t, err)
+	_, err = repo.Create(ctx, Task{Status: StatusQueued})
+	assert.NoError(t, err)
+
+	// Изменение, не записанное в журнал, не применяется в памяти.
+	repo.log.Close()
+	_, err = repo.Create(ctx, Task{Status: StatusQueued})
+	assert.Error(t, err)
+	_, err = repo.Update(ctx, 1, func(t Task) (Task, error) {
+		t.Status = StatusRunning
+		return t, nil
+	})
+	assert.Error(t, err)
+	assert.Error(t, repo.Delete(ctx, 1))
+
+	list, err := repo.List(ctx, ListQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, list.Tasks, 1)
+	assert.Equal(t, list.Tasks[0].Status, StatusQueued)
+}
+
+func TestRepositoryIdempotencyKey(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := NewFile(dir)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	key := IdempotencyKey{Key: "key", Fingerprint: "fp", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	existing, err := repo.ReserveIdempotencyKey(ctx, key)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+	existing, _ = repo.ReserveIdempotencyKey(ctx, key)
+	assert.NotNil(t, existing)
+	assert.Zero(t, existing.TaskID)
+
+	key.TaskID = 7
+	assert.NoError(t, repo.SaveIdempotencyKey(ctx, key))
+	expired := IdempotencyKey{Key: "expired", ExpiresAt: time.Now().Add(-time.Second).Unix()}
+	assert.NoError(t, repo.SaveIdempotencyKey(ctx, expired))
+
+	reopened, err := NewFile(dir)
+	assert.NoError(t, err)
+	existing, _ = reopened.ReserveIdempotencyKey(ctx, key)
+	assert.Equal(t, existing.TaskID, uint64(7))
+	// Просроченный ключ можно занять заново.
+	existing, _ = reopened.ReserveIdempotencyKey(ctx, expired)
+	assert.Nil(t, existing)
+
+	assert.NoError(t, reopened.DeleteIdempotencyKey(ctx, "key"))
+	existing, _ = reopened.ReserveIdempotencyKey(ctx, key)
+	assert.Nil(t, existing)
 }
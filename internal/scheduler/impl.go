@@ -0,0 +1,268 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"task-api/internal/operator"
+	"task-api/internal/repository"
+	"task-api/pkg/cron"
+	"task-api/pkg/timing"
+	"time"
+)
+
+type Schedule struct {
+	ID        uint64
+	Cron      string
+	TaskType  string
+	Options   map[string]any
+	Paused    bool
+	CreatedAt int64
+	NextRunAt int64
+	LastRunAt int64
+	// Задача, созданная последним срабатыванием.
+	LastTaskID uint64
+	// Ошибка последнего срабатывания, если задачу создать не удалось.
+	LastError string
+	// Пространство имен, в котором расписание создает задачи.
+	Namespace string
+	// Владелец ключа API, создавшего расписание, становится владельцем его задач.
+	Owner string
+}
+
+type entry struct {
+	schedule Schedule
+	cron     *cron.Schedule
+	timer    *time.Timer
+	// Поколение таймера: сработавший после паузы или удаления таймер игнорируется.
+	gen int
+}
+
+type scheduler struct {
+	operator    operator.Operator
+	constructor operator.Constructor
+
+	mu        sync.Mutex
+	currentID uint64
+	schedules map[uint64]*entry
+}
+
+var _ Scheduler = (*scheduler)(nil)
+
+func New(o operator.Operator, c operator.Constructor) *scheduler {
+	return &scheduler{
+		operator:    o,
+		constructor: c,
+		currentID:   1,
+		schedules:   make(map[uint64]*entry),
+	}
+}
+
+// Create implements Scheduler.
+func (s *scheduler) Create(ctx context.Context, cronExpr string, taskType string, options map[string]any, owner string) (*Schedule, error) {
+	c, err := cron.Parse(cronExpr)
+	if err != nil {
+		return nil, NewError(ErrCodeBadInput, err.Error())
+	}
+	if c.Next(time.Now().UTC()).IsZero() {
+		msg := fmt.Sprintf("выражение `%s` никогда не срабатывает", cronExpr)
+		return nil, NewError(ErrCodeBadInput, msg)
+	}
+	// Проверяем тип и параметры задачи заранее, а не при первом срабатывании.
+	task, err := s.constructor.Construct(taskType, options)
+	if err != nil {
+		return nil, NewError(ErrCodeBadInput, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &entry{
+		schedule: Schedule{
+			ID:        s.currentID,
+			Cron:      cronExpr,
+			TaskType:  taskType,
+			Options:   task.Options(),
+			CreatedAt: timing.Timestamp(),
+			Namespace: namespace(ctx),
+			Owner:     owner,
+		},
+		cron: c,
+	}
+	s.currentID++
+	s.schedules[e.schedule.ID] = e
+	s.arm(e)
+	schedule := e.schedule
+	return &schedule, nil
+}
+
+// List implements Scheduler.
+func (s *scheduler) List(ctx context.Context) ([]Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, e := range s.schedules {
+		if visible(ctx, e.schedule) {
+			schedules = append(schedules, e.schedule)
+		}
+	}
+	slices.SortFunc(schedules, func(a, b Schedule) int { return int(a.ID - b.ID) })
+	return schedules, nil
+}
+
+// Find implements Scheduler.
+func (s *scheduler) Find(ctx context.Context, scheduleID uint64) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	schedule := e.schedule
+	return &schedule, nil
+}
+
+// Pause implements Scheduler.
+func (s *scheduler) Pause(ctx context.Context, scheduleID uint64) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if !e.schedule.Paused {
+		s.disarm(e)
+		e.schedule.Paused = true
+		e.schedule.NextRunAt = 0
+	}
+	schedule := e.schedule
+	return &schedule, nil
+}
+
+// Resume implements Scheduler.
+func (s *scheduler) Resume(ctx context.Context, scheduleID uint64) (*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if e.schedule.Paused {
+		e.schedule.Paused = false
+		s.arm(e)
+	}
+	schedule := e.schedule
+	return &schedule, nil
+}
+
+// Delete implements Scheduler.
+func (s *scheduler) Delete(ctx context.Context, scheduleID uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.entry(ctx, scheduleID)
+	if err != nil {
+		return err
+	}
+	s.disarm(e)
+	delete(s.schedules, scheduleID)
+	return nil
+}
+
+// Останавливает все расписания.
+func (s *scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.schedules {
+		s.disarm(e)
+	}
+}
+
+func (s *scheduler) entry(ctx context.Context, scheduleID uint64) (*entry, error) {
+	e, ok := s.schedules[scheduleID]
+	if !ok || !visible(ctx, e.schedule) {
+		msg := fmt.Sprintf("расписание с id %d не найдено", scheduleID)
+		return nil, NewError(ErrCodeNotFound, msg)
+	}
+	return e, nil
+}
+
+// Взводит таймер на ближайшее срабатывание. Вызывается под блокировкой.
+func (s *scheduler) arm(e *entry) {
+	next := e.cron.Next(time.Now().UTC())
+	if next.IsZero() {
+		e.schedule.NextRunAt = 0
+		return
+	}
+	e.gen++
+	gen, id := e.gen, e.schedule.ID
+	e.schedule.NextRunAt = next.Unix()
+	e.timer = time.AfterFunc(time.Until(next), func() { s.tick(id, gen) })
+}
+
+func (s *scheduler) disarm(e *entry) {
+	e.gen++
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+func (s *scheduler) tick(scheduleID uint64, gen int) {
+	s.mu.Lock()
+	e, ok := s.schedules[scheduleID]
+	if !ok || e.gen != gen || e.schedule.Paused {
+		s.mu.Unlock()
+		return
+	}
+	schedule := e.schedule
+	s.mu.Unlock()
+
+	taskID, err := s.spawn(schedule)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok = s.schedules[scheduleID]
+	if !ok || e.gen != gen {
+		return
+	}
+	e.schedule.LastRunAt = timing.Timestamp()
+	e.schedule.LastError = ""
+	if err != nil {
+		slog.Error(fmt.Sprintf("расписание %d: не удалось создать задачу: %s", scheduleID, err))
+		e.schedule.LastError = err.Error()
+	} else {
+		e.schedule.LastTaskID = taskID
+	}
+	s.arm(e)
+}
+
+func (s *scheduler) spawn(schedule Schedule) (uint64, error) {
+	task, err := s.constructor.Construct(schedule.TaskType, schedule.Options)
+	if err != nil {
+		return 0, err
+	}
+	ctx := repository.WithNamespace(context.Background(), schedule.Namespace)
+	created, err := s.operator.Create(ctx, task, operator.CreateParams{
+		ScheduleID: schedule.ID,
+		Owner:      schedule.Owner,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+// Пространство имен запроса, как его понимает хранилище задач.
+func namespace(ctx context.Context) string {
+	if ns, ok := repository.NamespaceFromContext(ctx); ok {
+		return ns
+	}
+	return repository.DefaultNamespace
+}
+
+// Видно ли расписание запросу, ограниченному пространством имен.
+func visible(ctx context.Context, s Schedule) bool {
+	ns, ok := repository.NamespaceFromContext(ctx)
+	return !ok || s.Namespace == ns
+}
@@ -0,0 +1,15 @@
+package scheduler
+
+import "context"
+
+// Периодические расписания: по каждому срабатыванию cron-выражения
+// создается новая задача.
+type Scheduler interface {
+	// Задачи расписания создаются от имени владельца owner (пусто - без владельца).
+	Create(ctx context.Context, cronExpr string, taskType string, options map[string]any, owner string) (*Schedule, error)
+	List(ctx context.Context) ([]Schedule, error)
+	Find(ctx context.Context, scheduleID uint64) (*Schedule, error)
+	Pause(ctx context.Context, scheduleID uint64) (*Schedule, error)
+	Resume(ctx context.Context, scheduleID uint64) (*Schedule, error)
+	Delete(ctx context.Context, scheduleID uint64) error
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"task-api/internal/executor"
+	"task-api/internal/operator"
+	"task-api/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerCreate(t *testing.T) {
+	sched := New(&mockOper{}, &mockConstructor{})
+	ctx := context.Background()
+
+	schedule, err := sched.Create(ctx, "*/5 * * * *", "test", map[string]any{"test": 42}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.ID, uint64(1))
+	assert.NotZero(t, schedule.NextRunAt)
+	assert.False(t, schedule.Paused)
+
+	_, err = sched.Create(ctx, "* * *", "test", nil, "")
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+
+	_, err = sched.Create(ctx, "* * * * *", "unknown", nil, "")
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeBadInput)
+
+	list, err := sched.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	sched.Stop()
+}
+
+func TestSchedulerTick(t *testing.T) {
+	oper := &mockOper{}
+	sched := New(oper, &mockConstructor{})
+	ctx := context.Background()
+	defer sched.Stop()
+
+	schedule, _ := sched.Create(ctx, "* * * * *", "test", nil, "ann")
+	sched.tick(schedule.ID, sched.schedules[schedule.ID].gen)
+	assert.Equal(t, oper.params.ScheduleID, schedule.ID)
+	assert.Equal(t, oper.params.Owner, "ann")
+	found, err := sched.Find(ctx, schedule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, found.LastTaskID, uint64(42))
+	assert.NotZero(t, found.LastRunAt)
+	assert.NotZero(t, found.NextRunAt)
+
+	oper.err = fmt.Errorf("очередь задач заполнена")
+	sched.tick(schedule.ID, sched.schedules[schedule.ID].gen)
+	found, _ = sched.Find(ctx, schedule.ID)
+	assert.Equal(t, found.LastError, "очередь задач заполнена")
+	assert.NotZero(t, found.NextRunAt)
+}
+
+func TestSchedulerPauseResumeDelete(t *testing.T) {
+	oper := &mockOper{}
+	sched := New(oper, &mockConstructor{})
+	ctx := context.Background()
+	defer sched.Stop()
+
+	schedule, _ := sched.Create(ctx, "* * * * *", "test", nil, "")
+	gen := sched.schedules[schedule.ID].gen
+	paused, err := sched.Pause(ctx, schedule.ID)
+	assert.NoError(t, err)
+	assert.True(t, paused.Paused)
+	assert.Zero(t, paused.NextRunAt)
+
+	// Таймер, сработавший до паузы, игнорируется.
+	sched.tick(schedule.ID, gen)
+	assert.Equal(t, oper.calls, 0)
+
+	resumed, err := sched.Resume(ctx, schedule.ID)
+	assert.NoError(t, err)
+	assert.False(t, resumed.Paused)
+	assert.NotZero(t, resumed.NextRunAt)
+
+	assert.NoError(t, sched.Delete(ctx, schedule.ID))
+	_, err = sched.Find(ctx, schedule.ID)
+	assert.IsType(t, &Error{}, err)
+	assert.Equal(t, err.(*Error).Code(), ErrCodeNotFound)
+	_, err = sched.Pause(ctx, schedule.ID)
+	assert.Error(t, err)
+}
+
+func TestSchedulerOwnerQuota(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	key := executor.QuotaKey{Scope: executor.QuotaScopeOwner, Name: "ann"}
+	oper := operator.New(repository.New(), executor.New(executor.WithWorkers(2), executor.WithQuota(key, 1)))
+	sched := New(oper, &mockConstructor{release})
+	ctx := context.Background()
+	defer sched.Stop()
+
+	// Запуски расписания учитываются в квоте ключа, создавшего расписание.
+	schedule, _ := sched.Create(ctx, "* * * * *", "test", nil, "ann")
+	sched.tick(schedule.ID, sched.schedules[schedule.ID].gen)
+	sched.tick(schedule.ID, sched.schedules[schedule.ID].gen)
+	assert.Eventually(t, func() bool {
+		for _, q := range oper.Quotas(ctx) {
+			if q.QuotaKey == key {
+				return q.Running == 1 && q.Queued == 1
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+}
+
+type mockConstructor struct {
+	// Если задан, задачи выполняются до его закрытия.
+	release chan struct{}
+}
+
+// Construct implements operator.Constructor.
+func (c *mockConstructor) Construct(taskType string, opts map[string]any) (operator.Task, error) {
+	if taskType != "test" {
+		return nil, fmt.Errorf("тип задачи неизвестен: %s", taskType)
+	}
+	return &mockTask{c.release}, nil
+}
+
+type mockOper struct {
+	mu     sync.Mutex
+	calls  int
+	params operator.CreateParams
+	err    error
+}
+
+// Create implements operator.Operator.
+func (o *mockOper) Create(ctx context.Context, task operator.Task, params operator.CreateParams) (*repository.Task, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls++
+	o.params = params
+	if o.err != nil {
+		return nil, o.err
+	}
+	return &repository.Task{ID: 42, ScheduleID: params.ScheduleID}, nil
+}
+
+// CreateWorkflow implements operator.Operator.
+func (o *mockOper) CreateWorkflow(ctx context.Context, nodes []operator.WorkflowNode) ([]*repository.Task, error) {
+	panic("unimplemented")
+}
+
+// Cancel implements operator.Operator.
+func (o *mockOper) Cancel(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	panic("unimplemented")
+}
+
+// Delete implements operator.Operator.
+func (o *mockOper) Delete(ctx context.Context, taskID uint64) error {
+	panic("unimplemented")
+}
+
+// Quotas implements operator.Operator.
+func (o *mockOper) Quotas(ctx context.Context) []executor.Quota {
+	return nil
+}
+
+// QueuePosition implements operator.Operator.
+func (o *mockOper) QueuePosition(ctx context.Context, taskID uint64) (int, bool) {
+	return 0, false
+}
+
+// Wait implements operator.Operator.
+func (o *mockOper) Wait(ctx context.Context, taskID uint64) (*repository.Task, error) {
+	panic("unimplemented")
+}
+
+type mockTask struct {
+	release chan struct{}
+}
+
+// Execute implements operator.Task.
+func (m *mockTask) Execute(context.Context) (any, error) {
+	if m.release != nil {
+		<-m.release
+	}
+	return nil, nil
+}
+
+// Options implements operator.Task.
+func (m *mockTask) Options() map[string]any {
+	return map[string]any{}
+}
+
+// Type implements operator.Task.
+func (m *mockTask) Type() string {
+	return "test"
+}
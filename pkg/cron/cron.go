@@ -0,0 +1,163 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Расписание в формате cron из 5 полей: минуты, часы, день месяца, месяц,
+// день недели. Поддерживаются `*`, списки `1,2`, диапазоны `1-5` и шаги `*/15`, `1-30/5`.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// День месяца и день недели ограничены одновременно:
+	// тогда достаточно совпадения любого из них, как в классическом cron.
+	domRestricted, dowRestricted bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteBounds = bounds{"минуты", 0, 59}
+	hourBounds   = bounds{"часы", 0, 23}
+	domBounds    = bounds{"день месяца", 1, 31}
+	monthBounds  = bounds{"месяц", 1, 12}
+	dowBounds    = bounds{"день недели", 0, 7}
+)
+
+// Горизонт поиска следующего срабатывания.
+const searchYears = 5
+
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("выражение cron должно содержать 5 полей, получено %d", len(fields))
+	}
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	// 7 - тоже воскресенье.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return &s, nil
+}
+
+// Ближайшее время срабатывания строго после t с точностью до минуты.
+// Возвращает нулевое время, если срабатываний нет (например, `0 0 30 2 *`).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(searchYears, 0, 0)
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		bits, err := parsePart(part, b)
+		if err != nil {
+			return 0, err
+		}
+		set |= bits
+	}
+	return set, nil
+}
+
+func parsePart(part string, b bounds) (uint64, error) {
+	rng, stepStr, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepStr)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("поле %s: неверный шаг `%s`", b.name, stepStr)
+		}
+		step = n
+	}
+	lo, hi := b.min, b.max
+	if rng != "*" {
+		loStr, hiStr, isRange := strings.Cut(rng, "-")
+		var err error
+		if lo, err = parseValue(loStr, b); err != nil {
+			return 0, err
+		}
+		hi = lo
+		if isRange {
+			if hi, err = parseValue(hiStr, b); err != nil {
+				return 0, err
+			}
+		} else if hasStep {
+			hi = b.max
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("поле %s: неверный диапазон `%s`", b.name, rng)
+		}
+	}
+	var set uint64
+	for v := lo; v <= hi; v += step {
+		set |= 1 << uint(v)
+	}
+	return set, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("поле %s: неверное значение `%s`", b.name, s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("поле %s: значение %d вне диапазона %d-%d", b.name, v, b.min, b.max)
+	}
+	return v, nil
+}
@@ -0,0 +1,47 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronParse(t *testing.T) {
+	_, err := Parse("* * * * *")
+	assert.NoError(t, err)
+	_, err = Parse("*/15 9-18 1,15 * 1-5")
+	assert.NoError(t, err)
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err = Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2025, time.March, 14, 10, 17, 30, 0, time.UTC)
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.March, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, time.March, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		// 14.03.2025 - пятница, ближайший понедельник - 17.03.
+		{"30 8 * * 1", time.Date(2025, time.March, 17, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		// День месяца или день недели.
+		{"0 0 20 * 6", time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr)
+		assert.NoError(t, err)
+		assert.Equal(t, c.next, s.Next(base), c.expr)
+	}
+
+	s, _ := Parse("0 0 30 2 *")
+	assert.True(t, s.Next(base).IsZero())
+}
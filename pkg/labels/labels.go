@@ -0,0 +1,203 @@
+package labels
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+const maxLen = 63
+
+// Условие на одну метку.
+type Requirement struct {
+	Key    string
+	Op     Operator
+	Values []string
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Op {
+	case Equals:
+		return ok && value == r.Values[0]
+	case NotEquals:
+		return !ok || value != r.Values[0]
+	case In:
+		return ok && slices.Contains(r.Values, value)
+	case NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+// Селектор меток в стиле Kubernetes: условия через запятую, все должны выполняться.
+// Пустой селектор подходит любой задаче.
+type Selector []Requirement
+
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Селектор, требующий точного совпадения всех меток m.
+func FromMap(m map[string]string) Selector {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	s := make(Selector, 0, len(keys))
+	for _, k := range keys {
+		s = append(s, Requirement{Key: k, Op: Equals, Values: []string{m[k]}})
+	}
+	return s
+}
+
+// Разбирает селектор вида `env=prod,team!=ml,tier in (a,b),!canary`.
+// Поддерживаются `=`, `==`, `!=`, `in`, `notin`, наличие `key` и отсутствие `!key`.
+func Parse(s string) (Selector, error) {
+	var selector Selector
+	for _, term := range splitTerms(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			if strings.TrimSpace(s) == "" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("пустое условие в селекторе `%s`", s)
+		}
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, r)
+	}
+	return selector, nil
+}
+
+// Делит селектор по запятым вне скобок.
+func splitTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	if open := strings.IndexByte(term, '('); open >= 0 {
+		return parseSet(term, open)
+	}
+	for _, op := range []string{"!=", "==", "="} {
+		key, value, ok := strings.Cut(term, op)
+		if !ok {
+			continue
+		}
+		r := Requirement{Key: strings.TrimSpace(key), Op: Equals, Values: []string{strings.TrimSpace(value)}}
+		if op == "!=" {
+			r.Op = NotEquals
+		}
+		if err := ValidateKey(r.Key); err != nil {
+			return r, err
+		}
+		return r, ValidateValue(r.Values[0])
+	}
+	if key, ok := strings.CutPrefix(term, "!"); ok {
+		r := Requirement{Key: strings.TrimSpace(key), Op: DoesNotExist}
+		return r, ValidateKey(r.Key)
+	}
+	r := Requirement{Key: term, Op: Exists}
+	return r, ValidateKey(r.Key)
+}
+
+func parseSet(term string, open int) (Requirement, error) {
+	var r Requirement
+	fields := strings.Fields(term[:open])
+	if len(fields) != 2 || !strings.HasSuffix(term, ")") {
+		return r, fmt.Errorf("неверное условие `%s`", term)
+	}
+	r.Key = fields[0]
+	switch Operator(fields[1]) {
+	case In, NotIn:
+		r.Op = Operator(fields[1])
+	default:
+		return r, fmt.Errorf("неверный оператор `%s` в условии `%s`", fields[1], term)
+	}
+	if err := ValidateKey(r.Key); err != nil {
+		return r, err
+	}
+	for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+		value = strings.TrimSpace(value)
+		if err := ValidateValue(value); err != nil {
+			return r, err
+		}
+		r.Values = append(r.Values, value)
+	}
+	return r, nil
+}
+
+// Ключ метки: до 63 символов из букв, цифр, `-`, `_`, `.`, `/`,
+// начинается и заканчивается буквой или цифрой.
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("ключ метки не может быть пустым")
+	}
+	if !valid(key, "-_./") {
+		return fmt.Errorf("неверный ключ метки `%s`", key)
+	}
+	return nil
+}
+
+// Значение метки: пустое или до 63 символов из букв, цифр, `-`, `_`, `.`,
+// начинается и заканчивается буквой или цифрой.
+func ValidateValue(value string) error {
+	if value != "" && !valid(value, "-_.") {
+		return fmt.Errorf("неверное значение метки `%s`", value)
+	}
+	return nil
+}
+
+func valid(s string, extra string) bool {
+	if len(s) > maxLen || !alnum(rune(s[0])) || !alnum(rune(s[len(s)-1])) {
+		return false
+	}
+	for _, c := range s {
+		if !alnum(c) && !strings.ContainsRune(extra, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func alnum(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,47 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse("env=prod, team!=ml,tier in (web, api),!canary,owner")
+	assert.NoError(t, err)
+	assert.Len(t, s, 5)
+	assert.Equal(t, s[2], Requirement{Key: "tier", Op: In, Values: []string{"web", "api"}})
+	assert.Equal(t, s[3].Op, DoesNotExist)
+	assert.Equal(t, s[4].Op, Exists)
+
+	s, err = Parse("")
+	assert.NoError(t, err)
+	assert.Empty(t, s)
+
+	for _, bad := range []string{"env=prod,", "=prod", "env=pr od", "tier in web", "tier has (a)", "-env"} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "core", "owner": "ann"}
+	cases := map[string]bool{
+		"env=prod":              true,
+		"env==prod,team!=ml":    true,
+		"env=dev":               false,
+		"team in (core, infra)": true,
+		"team notin (core)":     false,
+		"canary":                false,
+		"!canary,owner":         true,
+		"region!=eu":            true,
+		"":                      true,
+	}
+	for expr, want := range cases {
+		s, err := Parse(expr)
+		assert.NoError(t, err)
+		assert.Equal(t, s.Matches(labels), want, expr)
+	}
+	assert.True(t, FromMap(map[string]string{"env": "prod"}).Matches(labels))
+	assert.False(t, FromMap(map[string]string{"env": "dev"}).Matches(labels))
+}
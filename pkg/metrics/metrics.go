@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Границы корзин гистограммы длительности в секундах по умолчанию.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type metric interface {
+	describe() *desc
+	write(w *bufio.Writer)
+}
+
+// Набор метрик, отдаваемых в текстовом формате Prometheus.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Регистрирует метрику. Повторная регистрация имени - ошибка программы.
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := m.describe().name
+	for _, other := range r.metrics {
+		if other.describe().name == name {
+			panic(fmt.Sprintf("метрика %s уже зарегистрирована", name))
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// Счетчик с метками labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, labels: labels}, series: make(map[string]*counterSeries)}
+	r.register(c)
+	return c
+}
+
+// Показатель, значение которого вычисляется fn при каждом чтении метрик.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{desc: desc{name: name, help: help}, fn: fn})
+}
+
+// Гистограмма с метками labels и верхними границами корзин buckets по возрастанию.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Записывает все метрики в текстовом формате Prometheus.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Обработчик `GET /metrics`.
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	}
+}
+
+// Имя, описание и имена меток метрики.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, typ)
+}
+
+// Ключ ряда по значениям меток. Число значений должно совпадать с числом меток.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("метрика %s: ожидается %d значений меток, получено %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Метки ряда в формате `{name="value",...}`, дополнительная метка extra - последней.
+func (d *desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, label := range d.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escape.Replace(values[i])))
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[0], extra[1]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (d *desc) describe() *desc {
+	return d
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Ключи рядов по возрастанию, чтобы вывод не зависел от порядка обхода.
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Монотонно растущий счетчик.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Увеличивает на 1 ряд со значениями меток values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Увеличивает на v ряд со значениями меток values. Отрицательное v игнорируется.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: slices.Clone(values)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(s.values), formatFloat(s.value))
+	}
+}
+
+type gaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// Гистограмма распределения значений, например длительностей.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	// Число значений в каждой корзине, не накопительно.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Добавляет значение v в ряд со значениями меток values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.values, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(s.values), s.count)
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.Counter("requests_total", "Число запросов.", "endpoint", "code")
+	reg.GaugeFunc("queue", "Очередь.", func() float64 { return 3 })
+	durations := reg.Histogram("duration_seconds", "Длительность.", []float64{0.1, 1}, "endpoint")
+
+	requests.Inc("b", "200")
+	requests.Add(2, "a\"", "500")
+	durations.Observe(0.05, "a")
+	durations.Observe(1, "a")
+	durations.Observe(5, "a")
+
+	var b strings.Builder
+	assert.NoError(t, reg.Write(&b))
+	assert.Equal(t, b.String(), `# HELP requests_total Число запросов.
+# TYPE requests_total counter
+requests_total{endpoint="a\"",code="500"} 2
+requests_total{endpoint="b",code="200"} 1
+# HELP queue Очередь.
+# TYPE queue gauge
+queue 3
+# HELP duration_seconds Длительность.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{endpoint="a",le="0.1"} 1
+duration_seconds_bucket{endpoint="a",le="1"} 2
+duration_seconds_bucket{endpoint="a",le="+Inf"} 3
+duration_seconds_sum{endpoint="a"} 6.05
+duration_seconds_count{endpoint="a"} 3
+`)
+
+	w := httptest.NewRecorder()
+	reg.Handler()(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, w.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+
+	assert.Panics(t, func() { reg.Counter("queue", "") })
+	assert.Panics(t, func() { requests.Inc("a") })
+}
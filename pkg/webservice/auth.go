@@ -0,0 +1,187 @@
+package webservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Ключ API из файла конфигурации. Сам ключ не хранится, только его SHA-256.
+type APIKey struct {
+	// Имя владельца ключа, записывается в созданные им задачи.
+	Name string `json:"name"`
+	// SHA-256 ключа в шестнадцатеричном виде.
+	KeySHA256 string `json:"key_sha256"`
+	// Разрешенные эндпоинты: имя, префикс с `*` (`Tasks.*`) или `*`. Пусто - любые.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Разрешенные типы задач. Пусто - любые.
+	TaskTypes []string `json:"task_types,omitempty"`
+	// Ключ видит только созданные им задачи.
+	OwnTasksOnly bool `json:"own_tasks_only,omitempty"`
+	// Пространство имен, в котором работает ключ. Пусто - пространство по умолчанию.
+	Namespace string `json:"namespace,omitempty"`
+	// Администратор: выбирает любое пространство имен и видит все пространства.
+	Admin bool `json:"admin,omitempty"`
+	// Максимум одновременно выполняемых задач ключа. 0 - без ограничения.
+	MaxRunning int `json:"max_running,omitempty"`
+}
+
+// Владелец ключа, с которым выполняется запрос.
+type Principal struct {
+	Name         string
+	Endpoints    []string
+	TaskTypes    []string
+	OwnTasksOnly bool
+	Namespace    string
+	Admin        bool
+	MaxRunning   int
+}
+
+func (p *Principal) AllowsEndpoint(endpoint string) bool {
+	if len(p.Endpoints) == 0 {
+		return true
+	}
+	for _, pattern := range p.Endpoints {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(endpoint, prefix) {
+				return true
+			}
+		} else if pattern == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) AllowsTaskType(taskType string) bool {
+	return len(p.TaskTypes) == 0 || slices.Contains(p.TaskTypes, taskType)
+}
+
+type principalKey struct{}
+
+// Владелец ключа запроса, установленный APIKeyAuth.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Набор ключей API, индексированный по хешу ключа.
+type APIKeys struct {
+	byHash map[string]*Principal
+}
+
+func NewAPIKeys(keys ...APIKey) (*APIKeys, error) {
+	k := &APIKeys{byHash: make(map[string]*Principal, len(keys))}
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !validName(key.Name) {
+			return nil, fmt.Errorf("имя ключа API `%s` должно состоять из латинских букв, цифр, `-`, `_` и `.`", key.Name)
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("имя ключа API `%s` повторяется", key.Name)
+		}
+		names[key.Name] = true
+		if key.MaxRunning < 0 {
+			return nil, fmt.Errorf("ключ API `%s`: `max_running` не может быть отрицательным", key.Name)
+		}
+		hash := strings.ToLower(key.KeySHA256)
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("ключ API `%s`: `key_sha256` должен быть SHA-256 в шестнадцатеричном виде", key.Name)
+		}
+		k.byHash[hash] = &Principal{
+			Name:         key.Name,
+			Endpoints:    key.Endpoints,
+			TaskTypes:    key.TaskTypes,
+			OwnTasksOnly: key.OwnTasksOnly,
+			Namespace:    key.Namespace,
+			Admin:        key.Admin,
+			MaxRunning:   key.MaxRunning,
+		}
+	}
+	return k, nil
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Владельцы всех ключей, упорядоченные по имени.
+func (k *APIKeys) Principals() []*Principal {
+	principals := make([]*Principal, 0, len(k.byHash))
+	for _, p := range k.byHash {
+		principals = append(principals, p)
+	}
+	slices.SortFunc(principals, func(a, b *Principal) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return principals
+}
+
+// Загружает ключи из JSON-файла вида `{"keys": [{"name": ..., "key_sha256": ...}]}`.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("файл ключей API %s: %w", path, err)
+	}
+	return NewAPIKeys(config.Keys...)
+}
+
+// Находит владельца ключа из заголовка `Authorization: Bearer <ключ>` или `X-API-Key`.
+func (k *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		key = r.Header.Get("X-API-Key")
+	}
+	if key == "" {
+		return nil, &Error{code: ErrCodeUnauthenticated, err: fmt.Errorf("запрос не содержит ключ API")}
+	}
+	sum := sha256.Sum256([]byte(key))
+	p, ok := k.byHash[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, &Error{code: ErrCodeUnauthenticated, err: fmt.Errorf("неизвестный ключ API")}
+	}
+	return p, nil
+}
+
+// Пропускает только запросы с известным ключом API, которому разрешен эндпоинт,
+// и кладет владельца ключа в контекст.
+func APIKeyAuth(keys *APIKeys) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			p, err := keys.Authenticate(call.HTTPRequest)
+			if err != nil {
+				call.Header.Set("WWW-Authenticate", "Bearer")
+				return err
+			}
+			if !p.AllowsEndpoint(call.Endpoint) {
+				err := fmt.Errorf("ключу API `%s` не разрешен эндпоинт `%s`", p.Name, call.Endpoint)
+				return &Error{code: ErrCodeForbidden, err: err}
+			}
+			return next(WithPrincipal(ctx, p), call)
+		}
+	}
+}
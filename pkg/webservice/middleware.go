@@ -0,0 +1,152 @@
+package webservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"task-api/pkg/metrics"
+	"time"
+)
+
+// Вызов эндпоинта, доступный промежуточным обработчикам.
+type Call struct {
+	Endpoint string
+	// Типы запроса и ответа эндпоинта.
+	RequestType  reflect.Type
+	ResponseType reflect.Type
+	// Декодированный запрос (*T), заполняется перед вызовом обработчика эндпоинта.
+	Request any
+	// Ответ (*U), заполняется после успешного вызова обработчика эндпоинта.
+	Response    any
+	HTTPRequest *http.Request
+	// Заголовки ответа.
+	Header http.Header
+
+	service *service
+}
+
+// HTTP-код ответа, который получит клиент при ошибке err (nil - успех).
+func (c *Call) StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	_, status := c.service.mapError(errorCode(err))
+	return status
+}
+
+// Обработчик вызова эндпоинта.
+type Handler func(ctx context.Context, call *Call) error
+
+// Промежуточный обработчик: получает следующий обработчик цепочки и решает, вызывать ли его.
+type Middleware func(next Handler) Handler
+
+func chain(h Handler, middlewares []Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// Идентификатор запроса, назначенный RequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Назначает запросу идентификатор из заголовка X-Request-ID или новый случайный,
+// возвращает его в том же заголовке ответа и кладет в контекст.
+func RequestID() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			id := call.HTTPRequest.Header.Get(RequestIDHeader)
+			if id == "" || len(id) > 128 {
+				id = newRequestID()
+			}
+			call.Header.Set(RequestIDHeader, id)
+			return next(context.WithValue(ctx, requestIDKey{}, id), call)
+		}
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Превращает панику в обработчике в ошибку, чтобы клиент получил ответ, а сервер продолжил работу.
+func Recovery(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logger.ErrorContext(ctx, "паника при обработке запроса",
+						"endpoint", call.Endpoint,
+						"request_id", RequestIDFromContext(ctx),
+						"panic", p,
+						"stack", string(debug.Stack()),
+					)
+					err = fmt.Errorf("паника при обработке запроса: %v", p)
+				}
+			}()
+			return next(ctx, call)
+		}
+	}
+}
+
+// Записывает в журнал каждый вызов: эндпоинт, код ответа, длительность и ошибку.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			start := time.Now()
+			err := next(ctx, call)
+			status := call.StatusCode(err)
+			attrs := []any{
+				"endpoint", call.Endpoint,
+				"status", status,
+				"duration", time.Since(start),
+				"remote_addr", call.HTTPRequest.RemoteAddr,
+			}
+			if id := RequestIDFromContext(ctx); id != "" {
+				attrs = append(attrs, "request_id", id)
+			}
+			level := slog.LevelInfo
+			if err != nil {
+				attrs = append(attrs, "error", err.Error())
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+			}
+			logger.Log(ctx, level, "запрос обработан", attrs...)
+			return err
+		}
+	}
+}
+
+// Считает запросы к эндпоинтам по кодам ответа и их длительность в метриках
+// api_requests_total и api_request_duration_seconds. Подключается до Recovery,
+// чтобы учитывать запросы, завершившиеся паникой.
+func RequestMetrics(r *metrics.Registry) Middleware {
+	requests := r.Counter("api_requests_total", "Число запросов к эндпоинтам по кодам ответа.", "endpoint", "code")
+	durations := r.Histogram("api_request_duration_seconds", "Длительность обработки запросов к эндпоинтам.",
+		metrics.DefaultBuckets, "endpoint")
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			start := time.Now()
+			err := next(ctx, call)
+			requests.Inc(call.Endpoint, strconv.Itoa(call.StatusCode(err)))
+			durations.Observe(time.Since(start).Seconds(), call.Endpoint)
+			return err
+		}
+	}
+}
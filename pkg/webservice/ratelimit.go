@@ -0,0 +1,213 @@
+package webservice
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ограничение частоты запросов: Limit запросов в секунду, всплеск до Burst запросов.
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+const (
+	defaultMaxBuckets  = 10000
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+type RateLimitOption func(l *RateLimiter)
+
+// Ограничение частоты запросов к эндпоинту вместо ограничения по умолчанию.
+func WithEndpointRate(endpoint string, r Rate) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.endpoints[endpoint] = r
+	}
+}
+
+// Максимальное число хранимых корзин. Корзина заводится на каждую пару клиента
+// и эндпоинта, поэтому клиент, вызывающий N эндпоинтов, занимает N корзин.
+// При переполнении вытесняется корзина, к которой дольше всех не обращались.
+func WithMaxBuckets(n int) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.maxBuckets = n
+	}
+}
+
+// Время без запросов, после которого корзина клиента удаляется.
+func WithIdleTimeout(d time.Duration) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.idleTimeout = d
+	}
+}
+
+// Различает клиентов по ключам API keys до проверки ключа в APIKeyAuth:
+// запросы с неизвестным ключом ограничиваются по IP-адресу.
+func WithAPIKeys(keys *APIKeys) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.keys = keys
+	}
+}
+
+// Ограничитель частоты запросов по алгоритму token bucket:
+// у каждого клиента своя корзина на каждый эндпоинт.
+type RateLimiter struct {
+	rate        Rate
+	endpoints   map[string]Rate
+	maxBuckets  int
+	idleTimeout time.Duration
+	keys        *APIKeys
+	now         func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*list.Element
+	// Корзины от недавно использованных к давно не использованным.
+	lru *list.List
+}
+
+type bucketKey struct {
+	client   string
+	endpoint string
+}
+
+type bucket struct {
+	key    bucketKey
+	tokens float64
+	last   time.Time
+}
+
+// Ограничитель с ограничением rate для эндпоинтов без собственного ограничения.
+// Нулевой rate - эндпоинты без собственного ограничения не ограничены.
+func NewRateLimiter(rate Rate, opts ...RateLimitOption) *RateLimiter {
+	l := &RateLimiter{
+		rate:        rate,
+		endpoints:   make(map[string]Rate),
+		maxBuckets:  defaultMaxBuckets,
+		idleTimeout: defaultIdleTimeout,
+		now:         time.Now,
+		buckets:     make(map[bucketKey]*list.Element),
+		lru:         list.New(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Результат проверки запроса.
+type rateDecision struct {
+	allowed   bool
+	limit     int
+	remaining int
+	// Время до следующего разрешенного запроса (если запрос отклонен)
+	// и до полного восстановления корзины.
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func (l *RateLimiter) allow(client, endpoint string) (rateDecision, bool) {
+	rate, ok := l.endpoints[endpoint]
+	if !ok {
+		rate = l.rate
+	}
+	if rate.Limit <= 0 {
+		return rateDecision{}, false
+	}
+	burst := max(rate.Burst, 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.evictIdle(now)
+	key := bucketKey{client, endpoint}
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+		b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate.Limit)
+		b.last = now
+	} else {
+		if l.lru.Len() >= l.maxBuckets {
+			l.remove(l.lru.Back())
+		}
+		b = &bucket{key: key, tokens: float64(burst), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	d := rateDecision{limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.allowed = true
+	} else {
+		d.retryAfter = seconds((1 - b.tokens) / rate.Limit)
+	}
+	d.remaining = int(b.tokens)
+	d.reset = seconds((float64(burst) - b.tokens) / rate.Limit)
+	return d, true
+}
+
+// Удаляет корзины клиентов, не обращавшихся к сервису дольше idleTimeout.
+func (l *RateLimiter) evictIdle(now time.Time) {
+	for e := l.lru.Back(); e != nil && now.Sub(e.Value.(*bucket).last) > l.idleTimeout; e = l.lru.Back() {
+		l.remove(e)
+	}
+}
+
+func (l *RateLimiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Клиент запроса: владелец ключа API, если запрос аутентифицирован, иначе IP-адрес.
+func (l *RateLimiter) client(ctx context.Context, call *Call) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return "key:" + p.Name
+	}
+	if l.keys != nil {
+		if p, err := l.keys.Authenticate(call.HTTPRequest); err == nil {
+			return "key:" + p.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(call.HTTPRequest.RemoteAddr)
+	if err != nil {
+		host = call.HTTPRequest.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Ограничивает частоту запросов клиента к эндпоинту. Сообщает ограничение в заголовках
+// X-RateLimit-*, а запрос сверх ограничения отклоняет с ErrCodeRateLimited и Retry-After.
+// Подключается до APIKeyAuth с WithAPIKeys, чтобы подбор ключей тоже ограничивался.
+func RateLimit(l *RateLimiter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			d, limited := l.allow(l.client(ctx, call), call.Endpoint)
+			if !limited {
+				return next(ctx, call)
+			}
+			call.Header.Set("X-RateLimit-Limit", strconv.Itoa(d.limit))
+			call.Header.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+			call.Header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+			if !d.allowed {
+				call.Header.Set("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+				err := fmt.Errorf("превышено ограничение частоты запросов к `%s`", call.Endpoint)
+				return &Error{code: ErrCodeRateLimited, err: err}
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
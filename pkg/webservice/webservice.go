@@ -3,10 +3,13 @@ package webservice
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 type ErrCode int
@@ -17,17 +20,53 @@ const (
 	ErrCodeMalformedEndpointHeader
 	ErrCodeUnsupportedEndpoint
 	ErrCodeClientCode
+	// Ключ API не передан или неизвестен.
+	ErrCodeUnauthenticated
+	// Ключу API не разрешен эндпоинт.
+	ErrCodeForbidden
+	// Превышено ограничение частоты запросов.
+	ErrCodeRateLimited
 )
 
 type ErrorMapper = func(code ErrCode, e error) (any, int)
 
+// Ошибка разбора или проверки запроса.
+type Error struct {
+	code ErrCode
+	err  error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Code() ErrCode {
+	return e.code
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Код и исходная ошибка для ErrorMapper. Ошибки обработчиков и
+// промежуточных обработчиков получают код ErrCodeClientCode.
+func errorCode(err error) (ErrCode, error) {
+	var wsErr *Error
+	if errors.As(err, &wsErr) {
+		return wsErr.code, wsErr.err
+	}
+	return ErrCodeClientCode, err
+}
+
 type Validator interface {
 	Validate() error
 }
 
 type service struct {
-	handlers  map[string]http.HandlerFunc
-	errMapper ErrorMapper
+	handlers    map[string]http.HandlerFunc
+	errMapper   ErrorMapper
+	timeout     time.Duration
+	middlewares []Middleware
 }
 
 func New() *service {
@@ -40,38 +79,132 @@ func (s *service) WithErrorMapper(m ErrorMapper) {
 	s.errMapper = m
 }
 
+// Ограничение времени обработки запроса для эндпоинтов без собственного ограничения.
+// 0 - без ограничения.
+func (s *service) WithDefaultTimeout(d time.Duration) {
+	s.timeout = d
+}
+
+// Добавляет промежуточные обработчики всех эндпоинтов сервиса.
+// Первый добавленный обработчик вызывается первым.
+func (s *service) Use(m ...Middleware) {
+	s.middlewares = append(s.middlewares, m...)
+}
+
+type endpoint struct {
+	timeout     time.Duration
+	middlewares []Middleware
+}
+
+type EndpointOption func(e *endpoint)
+
+// Ограничение времени обработки запроса эндпоинтом вместо ограничения по умолчанию.
+func WithTimeout(d time.Duration) EndpointOption {
+	return func(e *endpoint) {
+		e.timeout = d
+	}
+}
+
+// Промежуточные обработчики эндпоинта. Вызываются после обработчиков сервиса.
+func WithMiddleware(m ...Middleware) EndpointOption {
+	return func(e *endpoint) {
+		e.middlewares = append(e.middlewares, m...)
+	}
+}
+
 // Registers handler on an endpoint
-func Register[T any, U any](s *service, endpoint string, h func(context.Context, *T, *U) error) {
-	s.handlers[endpoint] = func(w http.ResponseWriter, r *http.Request) {
+func Register[T any, U any](s *service, name string, h func(context.Context, *T, *U) error, opts ...EndpointOption) {
+	var e endpoint
+	for _, opt := range opts {
+		opt(&e)
+	}
+	// Декодирует и проверяет запрос и вызывает обработчик эндпоинта.
+	var core Handler = func(ctx context.Context, call *Call) error {
 		var req T
 		if reflect.TypeFor[T]().NumField() > 0 {
-			dec := json.NewDecoder(r.Body)
+			dec := json.NewDecoder(call.HTTPRequest.Body)
 			err := dec.Decode(&req)
-			if err != nil {
-				s.writeError(w, ErrCodeJsonParsing, err)
-				return
+			// Пустое тело - запрос без параметров.
+			if err != nil && !errors.Is(err, io.EOF) {
+				return &Error{code: ErrCodeJsonParsing, err: err}
+			}
+			if err := bindHeaders(call.HTTPRequest, &req); err != nil {
+				return &Error{code: ErrCodeJsonBodyValidation, err: err}
 			}
 			if v, ok := any(req).(Validator); ok {
 				err := v.Validate()
 				if err != nil {
-					s.writeError(w, ErrCodeJsonBodyValidation, err)
-					return
+					return &Error{code: ErrCodeJsonBodyValidation, err: err}
 				}
 			}
 		}
+		call.Request = &req
 		var res U
-		err := h(context.Background(), &req, &res)
-		if err != nil {
-			s.writeError(w, ErrCodeClientCode, err)
+		if err := h(ctx, &req, &res); err != nil {
+			return err
+		}
+		call.Response = &res
+		return nil
+	}
+	s.handlers[name] = func(w http.ResponseWriter, r *http.Request) {
+		// Контекст запроса отменяется, когда клиент отключается.
+		ctx := r.Context()
+		timeout := e.timeout
+		if timeout == 0 {
+			timeout = s.timeout
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		call := &Call{
+			Endpoint:     name,
+			RequestType:  reflect.TypeFor[T](),
+			ResponseType: reflect.TypeFor[U](),
+			HTTPRequest:  r,
+			Header:       w.Header(),
+			service:      s,
+		}
+		handler := chain(core, e.middlewares)
+		handler = chain(handler, s.middlewares)
+		if err := handler(ctx, call); err != nil {
+			code, cause := errorCode(err)
+			s.writeError(w, code, cause)
 			return
 		}
 		enc := json.NewEncoder(w)
-		enc.Encode(res)
+		enc.Encode(call.Response)
 	}
-	logMsg := fmt.Sprintf("%s эндпоинт зарегистрирован (%s -> %s).", endpoint, reflect.TypeFor[T](), reflect.TypeFor[U]())
+	logMsg := fmt.Sprintf("%s эндпоинт зарегистрирован (%s -> %s).", name, reflect.TypeFor[T](), reflect.TypeFor[U]())
 	slog.Info(logMsg)
 }
 
+// Заполняет строковые поля запроса с тегом `header:"Name"` значением заголовка Name.
+// Заголовок и поле тела могут задавать одно значение, но не разные.
+func bindHeaders[T any](r *http.Request, req *T) error {
+	v := reflect.ValueOf(req).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Tag.Get("header")
+		field := v.Field(i)
+		if name == "" || field.Kind() != reflect.String {
+			continue
+		}
+		value := r.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if field.String() != "" && field.String() != value {
+			return fmt.Errorf("заголовок `%s` не совпадает со значением в теле запроса", name)
+		}
+		field.SetString(value)
+	}
+	return nil
+}
+
 func (s *service) endpoints() []string {
 	endpoints := make([]string, 0, len(s.handlers))
 	for endpoint := range s.handlers {
@@ -103,16 +236,18 @@ func (s *service) Handle(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *service) writeError(w http.ResponseWriter, errCode ErrCode, err error) {
+	rsp, code := s.mapError(errCode, err)
+	w.WriteHeader(code)
 	enc := json.NewEncoder(w)
+	enc.Encode(rsp)
+}
+
+func (s *service) mapError(errCode ErrCode, err error) (any, int) {
 	if s.errMapper != nil {
-		rsp, code := s.errMapper(errCode, err)
-		w.WriteHeader(code)
-		enc.Encode(rsp)
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-		enc.Encode(map[string]any{
-			"code":    errCode,
-			"message": err.Error(),
-		})
+		return s.errMapper(errCode, err)
 	}
+	return map[string]any{
+		"code":    errCode,
+		"message": err.Error(),
+	}, http.StatusBadRequest
 }
@@ -0,0 +1,253 @@
+package webservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-api/pkg/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type echoRequest struct {
+	Value string `json:"value"`
+}
+
+func (r echoRequest) Validate() error {
+	if r.Value == "" {
+		return fmt.Errorf("тело запроса не содержит поле `value`")
+	}
+	return nil
+}
+
+type echoResponse struct {
+	Value string `json:"value"`
+}
+
+func echo(ctx context.Context, req *echoRequest, res *echoResponse) error {
+	if req.Value == "panic" {
+		panic("boom")
+	}
+	res.Value = req.Value
+	return nil
+}
+
+func call(s *service, endpoint, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api", bytes.NewBufferString(body))
+	for k, v := range header {
+		r.Header.Set(k, v[0])
+	}
+	r.Header.Set("Endpoint", endpoint)
+	w := httptest.NewRecorder()
+	s.Handle(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New()
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, c *Call) error {
+				order = append(order, name)
+				err := next(ctx, c)
+				if c.Request != nil {
+					order = append(order, fmt.Sprintf("%s:%s", name, c.Request.(*echoRequest).Value))
+				}
+				return err
+			}
+		}
+	}
+	s.Use(RequestID(), AccessLog(logger), Recovery(logger), trace("service"))
+	Register(s, "Echo", echo, WithMiddleware(trace("endpoint")))
+	Register(s, "Plain", echo)
+
+	w := call(s, "Echo", `{"value": "hi"}`, http.Header{RequestIDHeader: {"req-1"}})
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get(RequestIDHeader), "req-1")
+	var res echoResponse
+	json.NewDecoder(w.Body).Decode(&res)
+	assert.Equal(t, res.Value, "hi")
+	assert.Equal(t, order, []string{"service", "endpoint", "endpoint:hi", "service:hi"})
+
+	// Промежуточные обработчики видят и ошибки разбора запроса.
+	order = nil
+	w = call(s, "Plain", `{}`, nil)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	assert.Equal(t, order, []string{"service"})
+
+	w = call(s, "Plain", `{"value": "panic"}`, nil)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestCallStatusCode(t *testing.T) {
+	s := New()
+	s.WithErrorMapper(func(code ErrCode, err error) (any, int) {
+		if code == ErrCodeClientCode {
+			return nil, http.StatusInternalServerError
+		}
+		return nil, http.StatusBadRequest
+	})
+	c := &Call{service: s}
+	assert.Equal(t, c.StatusCode(nil), http.StatusOK)
+	assert.Equal(t, c.StatusCode(fmt.Errorf("error")), http.StatusInternalServerError)
+	assert.Equal(t, c.StatusCode(&Error{code: ErrCodeJsonParsing, err: io.EOF}), http.StatusBadRequest)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	keys, err := NewAPIKeys(APIKey{
+		Name:      "ci",
+		KeySHA256: hex.EncodeToString(sum[:]),
+		Endpoints: []string{"Echo.*"},
+		TaskTypes: []string{"waiting"},
+	})
+	assert.NoError(t, err)
+	_, err = NewAPIKeys(APIKey{Name: "ci", KeySHA256: "secret"})
+	assert.Error(t, err)
+	_, err = NewAPIKeys(APIKey{Name: "ci", KeySHA256: hex.EncodeToString(sum[:]), MaxRunning: -1})
+	assert.Error(t, err)
+	assert.Equal(t, keys.Principals()[0].Name, "ci")
+
+	s := New()
+	s.WithErrorMapper(func(code ErrCode, err error) (any, int) {
+		switch code {
+		case ErrCodeUnauthenticated:
+			return nil, http.StatusUnauthorized
+		case ErrCodeForbidden:
+			return nil, http.StatusForbidden
+		}
+		return nil, http.StatusBadRequest
+	})
+	s.Use(APIKeyAuth(keys))
+	var principal *Principal
+	Register(s, "Echo.Get", func(ctx context.Context, req *echoRequest, res *echoResponse) error {
+		principal, _ = PrincipalFromContext(ctx)
+		return echo(ctx, req, res)
+	})
+	Register(s, "Other.Get", echo)
+
+	w := call(s, "Echo.Get", `{"value": "hi"}`, nil)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	assert.Equal(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+	w = call(s, "Echo.Get", `{"value": "hi"}`, http.Header{"Authorization": {"Bearer wrong"}})
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	w = call(s, "Other.Get", `{"value": "hi"}`, http.Header{"X-Api-Key": {"secret"}})
+	assert.Equal(t, w.Code, http.StatusForbidden)
+
+	w = call(s, "Echo.Get", `{"value": "hi"}`, http.Header{"Authorization": {"Bearer secret"}})
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, principal.Name, "ci")
+	assert.True(t, principal.AllowsTaskType("waiting"))
+	assert.False(t, principal.AllowsTaskType("other"))
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(Rate{Limit: 1, Burst: 2}, WithEndpointRate("Other.Get", Rate{}))
+	limiter.now = func() time.Time { return now }
+	s := New()
+	s.WithErrorMapper(func(code ErrCode, err error) (any, int) {
+		if code == ErrCodeRateLimited {
+			return nil, http.StatusTooManyRequests
+		}
+		return nil, http.StatusBadRequest
+	})
+	s.Use(RateLimit(limiter))
+	Register(s, "Echo.Get", echo)
+	Register(s, "Other.Get", echo)
+
+	w := call(s, "Echo.Get", `{"value": "hi"}`, nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("X-RateLimit-Limit"), "2")
+	assert.Equal(t, w.Header().Get("X-RateLimit-Remaining"), "1")
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, nil).Code, http.StatusOK)
+	w = call(s, "Echo.Get", `{"value": "hi"}`, nil)
+	assert.Equal(t, w.Code, http.StatusTooManyRequests)
+	assert.Equal(t, w.Header().Get("Retry-After"), "1")
+	assert.Equal(t, w.Header().Get("X-RateLimit-Reset"), "2")
+
+	// Эндпоинт без ограничения и другой эндпоинт с пустой корзиной клиента.
+	w = call(s, "Other.Get", `{"value": "hi"}`, nil)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+
+	now = now.Add(time.Second)
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, nil).Code, http.StatusOK)
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	keys, _ := NewAPIKeys(APIKey{Name: "ci", KeySHA256: hex.EncodeToString(sum[:])})
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(Rate{Limit: 1, Burst: 1}, WithAPIKeys(keys))
+	limiter.now = func() time.Time { return now }
+	s := New()
+	s.WithErrorMapper(func(code ErrCode, err error) (any, int) {
+		switch code {
+		case ErrCodeRateLimited:
+			return nil, http.StatusTooManyRequests
+		case ErrCodeUnauthenticated:
+			return nil, http.StatusUnauthorized
+		}
+		return nil, http.StatusBadRequest
+	})
+	s.Use(RateLimit(limiter), APIKeyAuth(keys))
+	Register(s, "Echo.Get", echo)
+
+	// Подбор ключей ограничивается по IP-адресу и не расходует корзину владельца ключа.
+	wrong := http.Header{"X-Api-Key": {"wrong"}}
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, wrong).Code, http.StatusUnauthorized)
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, wrong).Code, http.StatusTooManyRequests)
+	valid := http.Header{"X-Api-Key": {"secret"}}
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, valid).Code, http.StatusOK)
+	assert.Equal(t, call(s, "Echo.Get", `{"value": "hi"}`, valid).Code, http.StatusTooManyRequests)
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(Rate{Limit: 1, Burst: 1}, WithMaxBuckets(2), WithIdleTimeout(time.Minute))
+	limiter.now = func() time.Time { return now }
+
+	for _, client := range []string{"a", "b", "c"} {
+		d, _ := limiter.allow(client, "Echo.Get")
+		assert.True(t, d.allowed)
+	}
+	// Вытеснена корзина давно не обращавшегося клиента `a`.
+	assert.Equal(t, len(limiter.buckets), 2)
+	d, _ := limiter.allow("a", "Echo.Get")
+	assert.True(t, d.allowed)
+	d, _ = limiter.allow("c", "Echo.Get")
+	assert.False(t, d.allowed)
+
+	now = now.Add(2 * time.Minute)
+	limiter.allow("d", "Echo.Get")
+	assert.Equal(t, len(limiter.buckets), 1)
+}
+
+func TestRequestMetrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	s := New()
+	s.Use(RequestMetrics(reg))
+	Register(s, "Echo.Get", echo)
+
+	call(s, "Echo.Get", `{"value": "hi"}`, nil)
+	call(s, "Echo.Get", `{`, nil)
+	var b strings.Builder
+	assert.NoError(t, reg.Write(&b))
+	assert.Contains(t, b.String(), `api_requests_total{endpoint="Echo.Get",code="200"} 1`)
+	assert.Contains(t, b.String(), `api_requests_total{endpoint="Echo.Get",code="400"} 1`)
+	assert.Contains(t, b.String(), `api_request_duration_seconds_count{endpoint="Echo.Get"} 2`)
+}